@@ -2,56 +2,343 @@ package main
 
 import (
 	"context"
-	"log"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/wolfman30/api-gateway-go/internal/auth"
 	"github.com/wolfman30/api-gateway-go/internal/bus"
 	"github.com/wolfman30/api-gateway-go/internal/config"
+	"github.com/wolfman30/api-gateway-go/internal/cors"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
 	"github.com/wolfman30/api-gateway-go/internal/handlers"
+	"github.com/wolfman30/api-gateway-go/internal/health"
+	"github.com/wolfman30/api-gateway-go/internal/httpx"
+	"github.com/wolfman30/api-gateway-go/internal/logging"
+	"github.com/wolfman30/api-gateway-go/internal/metrics"
+	"github.com/wolfman30/api-gateway-go/internal/quota"
+	"github.com/wolfman30/api-gateway-go/internal/recovery"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+	"github.com/wolfman30/api-gateway-go/internal/router"
+	"github.com/wolfman30/api-gateway-go/internal/server"
+	"github.com/wolfman30/api-gateway-go/internal/store"
+	"github.com/wolfman30/api-gateway-go/internal/tlsconfig"
+	"github.com/wolfman30/api-gateway-go/internal/tracing"
 )
 
 func main() {
-	ctx := context.Background()
+	// Cancelled on SIGTERM (ECS task stop) or SIGINT to begin graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
-	// Load secrets from AWS Secrets Manager
-	secrets, err := config.LoadFromSecretsManager(ctx)
+	// Load configuration first so LOG_LEVEL applies to everything after it; every
+	// problem is reported before exiting
+	envConfig, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatalf("Failed to load secrets: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	_ = secrets // Will be used for authentication/database connection
-
-	// Load environment configuration
-	envConfig := config.LoadEnvironmentConfig()
-	log.Printf("Running in environment: %s", envConfig.Environment)
+	logging.Setup(os.Stdout, envConfig.LogLevel)
+	slog.Info("Running in environment", "environment", envConfig.Environment.String())
 
 	// Load AWS configuration
 	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
 	if err != nil {
-		log.Fatalf("Failed to load AWS config: %v", err)
+		fatal("Failed to load AWS config", err)
+	}
+
+	// Load secrets from the configured provider (AWS Secrets Manager by default);
+	// a missing required secret stops startup
+	secretsProvider, err := config.NewSecretsProvider(envConfig, awsCfg)
+	if err != nil {
+		fatal("Failed to create secrets provider", err)
+	}
+	secretsHolder, err := config.NewSecretsHolder(ctx, secretsProvider, envConfig.Environment, config.GatewaySecrets)
+	if err != nil {
+		fatal("Failed to load secrets", err)
+	}
+	secrets := secretsHolder.Current()
+
+	// Tracing from HTTP ingress through the SQS message
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName: envConfig.ServiceName,
+		Environment: envConfig.Environment.String(),
+		Exporter:    envConfig.TracesExporter,
+		Endpoint:    envConfig.OtlpEndpointURL,
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
 	}
 
 	// Create SQS client
 	sqsClient := sqs.NewFromConfig(awsCfg)
 
-	// Initialize SQS publisher with configured queue URL
-	publisher := bus.NewPublisher(envConfig.SqsQueueURL, sqsClient)
-	handlers.SetPublisher(publisher)
+	// Prometheus metrics on a dedicated registry
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	gatewayMetrics := metrics.New(registry)
+	handlers.SetMetrics(gatewayMetrics)
+
+	// Initialize SQS publisher with configured queue URL; without one POST /reels
+	// replies 503 not_configured
+	var publisher *bus.Publisher
+	if envConfig.SqsQueueURL != "" {
+		publisher = bus.NewPublisher(envConfig.SqsQueueURL, sqsClient)
+		publisher.SetObserver(gatewayMetrics)
+		handlers.SetPublisher(publisher)
+	} else {
+		slog.Warn("SQS_QUEUE_URL is not set: reel requests are rejected as not configured")
+	}
+	handlers.SetDecodeOptions(httpx.DecodeOptions{MaxBytes: envConfig.MaxRequestBodyBytes, Strict: envConfig.StrictJSON})
+	// State shared by every task lives in DynamoDB; without a table it is per process
+	var table *dynamo.Table
+	if envConfig.DynamoDBTable != "" {
+		table = dynamo.NewTable(dynamodb.NewFromConfig(awsCfg), envConfig.DynamoDBTable)
+	}
+
+	// Runs are read back through any task, so they live in the shared table;
+	// the in-memory store only sees runs accepted by this process
+	var runStore store.RunStore
+	if table != nil {
+		runStore = store.NewDynamoRunStore(table, envConfig.RunTTL)
+	} else {
+		runStore = store.NewMemoryRunStore(store.MemoryRunStoreOptions{TTL: envConfig.RunTTL})
+	}
+	handlers.SetRunStore(runStore)
+
+	// Reel quotas are counted in the shared table so the limit holds across
+	// tasks; without one each task counts on its own
+	if envConfig.ReelQuota > 0 {
+		var counter quota.Counter = quota.NewMemoryCounter()
+		if table != nil {
+			counter = quota.NewDynamoCounter(table)
+		}
+		handlers.SetQuota(quota.NewLimiter(counter, envConfig.ReelQuota, envConfig.ReelQuotaWindow))
+	}
+
+	// The shared api-key secret is the bootstrap admin key. Per-client API keys
+	// need the shared table: issued in memory, a key would only work on the task
+	// that issued it, so key management is disabled without one
+	adminKey := auth.NewStaticKeyAuthenticator("admin", secrets.ApiKey.Value(), auth.ScopeKeysAdmin)
+	authenticator := auth.Chain{adminKey}
+	apiRoutes := handlers.Routes()
+	if table != nil {
+		keyManager := auth.NewKeyManager(auth.NewDynamoKeyStore(table))
+		handlers.SetKeyManager(keyManager)
+		authenticator = append(authenticator, auth.NewAPIKeyAuthenticator(keyManager))
+		apiRoutes = append(apiRoutes, handlers.KeyRoutes()...)
+	} else {
+		slog.Warn("DYNAMODB_TABLE is not set: per-client API keys are disabled")
+	}
+
+	// OAuth2 client-credentials tokens, signed with the gateway JWT secret
+	clientScopes := []string{auth.ScopeReelsWrite, auth.ScopeRunsRead}
+	for _, project := range envConfig.OAuthClientProjects {
+		clientScopes = append(clientScopes, auth.ScopeProjectPrefix+project)
+	}
+	oauthClient := func(s *config.SecretsConfig) auth.OAuthClient {
+		return auth.OAuthClient{ID: s.OAuthClientID.Value(), Secret: s.OAuthClientSecret.Value(), Scopes: clientScopes}
+	}
+	tokenIssuer := auth.NewTokenIssuer([]byte(secrets.JwtSecret.Value()), "api-gateway-"+envConfig.Environment.String(), envConfig.OAuthTokenTTL)
+	tokenIssuer.SetKeys([]byte(secrets.JwtSecret.Value()), []byte(secrets.JwtSecretPrevious.Value()))
+	clientCredentials := auth.NewClientCredentials(tokenIssuer, oauthClient(secrets))
+	handlers.SetClientCredentials(clientCredentials)
+	authenticator = append(authenticator, auth.NewTokenAuthenticator(tokenIssuer))
+
+	// Server-to-server callers signing requests with a per-client secret. The
+	// authenticator is always installed so clients added by a rotation are accepted.
+	hmacClients, err := auth.ParseHMACClients(secrets.HMACClients.Value())
+	if err != nil {
+		fatal("Invalid hmac-clients secret", err)
+	}
+	// Nonces are shared through the table so a request cannot be replayed
+	// against another task; signed bodies obey the same limit as JSON bodies
+	hmacOptions := auth.HMACOptions{MaxBodyBytes: envConfig.MaxRequestBodyBytes}
+	if table != nil {
+		hmacOptions.Nonces = auth.NewDynamoNonceStore(table)
+	}
+	hmacAuthenticator := auth.NewHMACAuthenticator(hmacClients, hmacOptions)
+	authenticator = append(authenticator, hmacAuthenticator)
+	if len(hmacClients) > 0 {
+		slog.Info("Accepting HMAC-signed requests", "clients", len(hmacClients))
+	}
+
+	// Re-key the authenticators when secrets rotate
+	secretsHolder.Subscribe(func(s *config.SecretsConfig) {
+		adminKey.SetKey(s.ApiKey.Value())
+		tokenIssuer.SetKeys([]byte(s.JwtSecret.Value()), []byte(s.JwtSecretPrevious.Value()))
+		clientCredentials.SetClients(oauthClient(s))
+		clients, err := auth.ParseHMACClients(s.HMACClients.Value())
+		if err != nil {
+			slog.Error("Invalid rotated hmac-clients secret, keeping previous clients", "error", err)
+			return
+		}
+		hmacAuthenticator.SetClients(clients)
+	})
+	if envConfig.SecretsRefreshInterval > 0 {
+		go secretsHolder.Run(ctx, envConfig.SecretsRefreshInterval)
+	}
+
+	// Tokens from the external identity provider, verified against its JWKS
+	if envConfig.JwksURL != "" {
+		jwks := auth.NewJWKS(envConfig.JwksURL, auth.JWKSOptions{})
+		authenticator = append(authenticator, auth.NewExternalTokenAuthenticator(jwks, envConfig.JwtIssuer, envConfig.JwtAudience))
+		slog.Info("Verifying external tokens", "issuer", envConfig.JwtIssuer, "jwksUrl", envConfig.JwksURL)
+	}
+
+	// Callers presenting a client certificate verified against TLS_CLIENT_CA_FILE
+	if envConfig.TLSClientCAFile != "" {
+		identities, err := auth.ParseClientCertIdentities(envConfig.TLSClientIdentities)
+		if err != nil {
+			fatal("Invalid TLS_CLIENT_IDENTITIES", err)
+		}
+		authenticator = append(authenticator, auth.NewClientCertAuthenticator(identities))
+		slog.Info("Accepting client certificates", "identities", len(identities))
+	}
+
+	// Panics become 500 responses; they are logged, counted and sent to any extra reporters
+	recoverer := recovery.New()
+	recoverer.AddReporter(gatewayMetrics)
 
-	mux := http.NewServeMux()
+	// Every API route is authenticated with its scope and instrumented under its pattern,
+	// so metrics, spans and logs are labelled with the route, not the raw path
+	routes := router.New()
+	for _, route := range apiRoutes {
+		h := route.Handler
+		if route.Scope != "" {
+			h = auth.Require(authenticator, route.Scope, h)
+		}
+		// Innermost first: recovery sits inside logging and tracing so a panic is
+		// logged and traced as the 500 it becomes; the outer recoverer below
+		// catches everything else
+		h = recoverer.Middleware(route.Pattern, h)
+		h = logging.Middleware(route.Pattern, h)
+		h = tracing.Middleware(route.Pattern, h)
+		routes.Handle(route.Method, route.Pattern, h)
+	}
+	routes.Handle(http.MethodGet, "/metrics", metrics.Handler(registry).ServeHTTP)
 
-	// Register routes
-	mux.HandleFunc("/reels", handlers.CreateReel)
-	mux.HandleFunc("/runs/", handlers.GetRunStatus)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+	// Browser clients on allowed origins; preflights are answered before routing and auth
+	corsPolicy, err := cors.New(cors.Config{
+		AllowedOrigins:   envConfig.CorsAllowedOrigins,
+		AllowedMethods:   envConfig.CorsAllowedMethods,
+		AllowedHeaders:   envConfig.CorsAllowedHeaders,
+		ExposedHeaders:   []string{requestid.Header, "Preference-Applied", "WWW-Authenticate"},
+		AllowCredentials: envConfig.CorsAllowCredentials,
+		MaxAge:           envConfig.CorsMaxAge,
 	})
+	if err != nil {
+		fatal("Invalid CORS config", err)
+	}
+
+	// Native TLS, with certificates reloaded on rotation
+	var tlsCfg *tls.Config
+	if envConfig.TLSEnabled() {
+		tlsCfg, err = newTLSConfig(ctx, envConfig, secretsmanager.NewFromConfig(awsCfg))
+		if err != nil {
+			fatal("Invalid TLS config", err)
+		}
+	}
+
+	// Metrics and recovery wrap everything so router 404s/405s, auth and CORS
+	// rejections and panics are all counted; unmatched paths share one label
+	handler := gatewayMetrics.InstrumentHandler(routes.Pattern,
+		recoverer.Handler(routes.Pattern, requestid.Middleware(corsPolicy.Middleware(routes))))
 
 	addr := ":" + envConfig.ApiPort
-	log.Printf("Starting API gateway on %s", addr)
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	srv := server.New(server.Config{
+		Addr:              addr,
+		ReadHeaderTimeout: envConfig.ReadTimeout,
+		ReadTimeout:       envConfig.ReadTimeout,
+		WriteTimeout:      envConfig.WriteTimeout,
+		IdleTimeout:       envConfig.IdleTimeout,
+		DrainPeriod:       envConfig.ShutdownDrainPeriod,
+		ShutdownTimeout:   envConfig.ShutdownTimeout,
+		TLSConfig:         tlsCfg,
+	}, handler)
+	// Hooks run in reverse: close the publisher, then flush its spans
+	srv.OnShutdown(shutdownTracing)
+	if publisher != nil {
+		srv.OnShutdown(publisher.Close)
+	}
+
+	// Dependency checks for /readyz; results are cached to avoid hammering dependencies
+	checks := health.NewRegistry(envConfig.HealthCacheTTL, 2*time.Second)
+	if envConfig.SqsQueueURL != "" {
+		checks.Register(health.NewSQSCheck(sqsClient, envConfig.SqsQueueURL))
+	}
+	checks.Register(health.NewPingCheck("run-store", runStore))
+	if table != nil {
+		checks.Register(health.NewPingCheck("dynamodb", table))
+	}
+	checks.Register(health.NewSecretsFreshnessCheck(func() time.Time { return secretsHolder.Current().LoadedAt }, envConfig.SecretsMaxAge))
+
+	// Readiness fails as soon as shutdown begins so the load balancer stops sending traffic;
+	// /health is kept for existing health checks
+	routes.Handle(http.MethodGet, "/livez", health.LivezHandler)
+	routes.Handle(http.MethodGet, "/readyz", checks.ReadyzHandler(srv.Ready))
+	routes.Handle(http.MethodGet, "/health", srv.ReadinessHandler)
+	for _, route := range routes.Routes() {
+		slog.Debug("Registered route", "method", route.Method, "pattern", route.Pattern)
 	}
+
+	slog.Info("Starting API gateway", "addr", addr, "tls", tlsCfg != nil)
+	if err := srv.Run(ctx); err != nil {
+		fatal("Server failed", err)
+	}
+}
+
+// newTLSConfig loads the server certificate from files or Secrets Manager,
+// starts reloading it in the background and applies the client certificate policy.
+func newTLSConfig(ctx context.Context, envConfig *config.EnvironmentConfig, secretsClient tlsconfig.SecretValueClient) (*tls.Config, error) {
+	minVersion, err := tlsconfig.ParseMinVersion(envConfig.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	var source tlsconfig.Source = tlsconfig.FileSource{CertFile: envConfig.TLSCertFile, KeyFile: envConfig.TLSKeyFile}
+	if envConfig.TLSCertSecretID != "" {
+		source = tlsconfig.SecretsManagerSource{Client: secretsClient, SecretID: envConfig.TLSCertSecretID}
+	}
+	reloader, err := tlsconfig.NewReloader(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if envConfig.TLSReloadInterval > 0 {
+		go reloader.Watch(ctx, envConfig.TLSReloadInterval)
+	}
+
+	opts := tlsconfig.Options{MinVersion: minVersion}
+	if envConfig.TLSClientCAFile != "" {
+		if opts.ClientAuth, err = tlsconfig.ParseClientAuth(envConfig.TLSClientAuth); err != nil {
+			return nil, err
+		}
+		if opts.ClientCAs, err = tlsconfig.LoadCertPool(envConfig.TLSClientCAFile); err != nil {
+			return nil, err
+		}
+	}
+	slog.Info("Serving TLS", "minVersion", envConfig.TLSMinVersion, "notAfter", reloader.Certificate().Leaf.NotAfter, "mutualTls", opts.ClientCAs != nil)
+	return tlsconfig.New(reloader, opts), nil
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.KeyError, err)
+	os.Exit(1)
 }
@@ -0,0 +1,133 @@
+// Package apierror defines the gateway's error model. Every error response
+// is an application/problem+json body with a stable code clients can match
+// on, the request ID and whether the request is safe to retry.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/wolfman30/api-gateway-go/internal/models"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+)
+
+// ContentType is the media type of error responses (RFC 9457).
+const ContentType = "application/problem+json"
+
+// Code identifies an error condition. Codes are part of the public API and
+// must not change meaning once published.
+type Code string
+
+const (
+	CodeInvalidRequest    Code = "invalid_request"
+	CodeValidationFailed  Code = "validation_failed"
+	CodeUnauthorized      Code = "unauthorized"
+	CodeForbidden         Code = "forbidden"
+	CodeInsufficientScope Code = "insufficient_scope"
+	CodeNotFound          Code = "not_found"
+	CodeRunNotFound       Code = "run_not_found"
+	CodeAPIKeyNotFound    Code = "api_key_not_found"
+	CodeMethodNotAllowed  Code = "method_not_allowed"
+	CodeAPIKeyRevoked     Code = "api_key_revoked"
+	CodePayloadTooLarge   Code = "payload_too_large"
+	CodeUnsupportedMedia  Code = "unsupported_media_type"
+	CodeQuotaExceeded     Code = "quota_exceeded"
+	CodeStoreUnavailable  Code = "store_unavailable"
+	CodeEnqueueFailed     Code = "enqueue_failed"
+	CodeNotConfigured     Code = "not_configured"
+	CodeShuttingDown      Code = "shutting_down"
+	CodeInternal          Code = "internal_error"
+)
+
+// codeInfo fixes the HTTP status and retry advice of each code.
+var codeInfo = map[Code]struct {
+	status    int
+	retryable bool
+}{
+	CodeInvalidRequest:    {http.StatusBadRequest, false},
+	CodeValidationFailed:  {http.StatusBadRequest, false},
+	CodeUnauthorized:      {http.StatusUnauthorized, false},
+	CodeForbidden:         {http.StatusForbidden, false},
+	CodeInsufficientScope: {http.StatusForbidden, false},
+	CodeNotFound:          {http.StatusNotFound, false},
+	CodeRunNotFound:       {http.StatusNotFound, false},
+	CodeAPIKeyNotFound:    {http.StatusNotFound, false},
+	CodeMethodNotAllowed:  {http.StatusMethodNotAllowed, false},
+	CodeAPIKeyRevoked:     {http.StatusConflict, false},
+	CodePayloadTooLarge:   {http.StatusRequestEntityTooLarge, false},
+	CodeUnsupportedMedia:  {http.StatusUnsupportedMediaType, false},
+	CodeQuotaExceeded:     {http.StatusTooManyRequests, true},
+	CodeStoreUnavailable:  {http.StatusServiceUnavailable, true},
+	CodeEnqueueFailed:     {http.StatusServiceUnavailable, true},
+	CodeNotConfigured:     {http.StatusServiceUnavailable, false},
+	CodeShuttingDown:      {http.StatusServiceUnavailable, true},
+	CodeInternal:          {http.StatusInternalServerError, false},
+}
+
+// Error is an error that can be rendered to a client.
+type Error struct {
+	Status    int
+	Code      Code
+	Message   string
+	Details   any
+	Retryable bool
+	// Err is the underlying cause. It is logged, never sent to clients.
+	Err error
+}
+
+// New creates an Error with the status and retry advice registered for code.
+// Unknown codes are treated as internal errors.
+func New(code Code, message string) *Error {
+	info, ok := codeInfo[code]
+	if !ok {
+		info = codeInfo[CodeInternal]
+	}
+	return &Error{Status: info.status, Code: code, Message: message, Retryable: info.retryable}
+}
+
+// Wrap is New with an underlying cause.
+func Wrap(err error, code Code, message string) *Error {
+	e := New(code, message)
+	e.Err = err
+	return e
+}
+
+// WithDetails sets structured details, such as the fields that failed validation.
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Write renders err as a problem+json response. Errors that are not an
+// *Error are reported as internal errors without exposing their text.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Wrap(err, CodeInternal, "Internal error")
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Code:      string(e.Code),
+		Message:   e.Message,
+		Details:   e.Details,
+		RequestID: requestid.FromContext(r.Context()),
+		Retryable: e.Retryable,
+	})
+}
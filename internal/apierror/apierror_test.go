@@ -0,0 +1,87 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wolfman30/api-gateway-go/internal/models"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+)
+
+func TestCodesHaveStatus(t *testing.T) {
+	for code, info := range codeInfo {
+		if info.status < 400 || info.status > 599 {
+			t.Errorf("Code %s has non-error status %d", code, info.status)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		code      string
+		message   string
+		retryable bool
+	}{
+		{
+			name:   "validation with details",
+			err:    New(CodeValidationFailed, "missing required fields: idea").WithDetails(map[string][]string{"fields": {"idea"}}),
+			status: http.StatusBadRequest, code: "validation_failed", message: "missing required fields: idea",
+		},
+		{
+			name:   "retryable wrapped cause",
+			err:    Wrap(errors.New("sqs: throttled"), CodeEnqueueFailed, "Failed to enqueue reel command"),
+			status: http.StatusServiceUnavailable, code: "enqueue_failed", message: "Failed to enqueue reel command", retryable: true,
+		},
+		{
+			name:   "plain error hides its text",
+			err:    errors.New("dial tcp 10.0.0.1: refused"),
+			status: http.StatusInternalServerError, code: "internal_error", message: "Internal error",
+		},
+		{
+			name:   "unknown code",
+			err:    New("made_up", "oops"),
+			status: http.StatusInternalServerError, code: "made_up", message: "oops",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/reels", nil)
+			req = req.WithContext(requestid.WithRequestID(req.Context(), "req-1"))
+			rec := httptest.NewRecorder()
+
+			Write(rec, req, tt.err)
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+				t.Errorf("Expected Content-Type %s, got %s", ContentType, ct)
+			}
+			var body models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			if body.Status != tt.status || body.Code != tt.code || body.Message != tt.message || body.Retryable != tt.retryable {
+				t.Errorf("Unexpected body %+v", body)
+			}
+			if body.RequestID != "req-1" || body.Title != http.StatusText(tt.status) {
+				t.Errorf("Expected requestId and title, got %+v", body)
+			}
+		})
+	}
+}
+
+func TestError_Unwrap(t *testing.T) {
+	cause := errors.New("boom")
+	err := Wrap(cause, CodeStoreUnavailable, "Failed to load run")
+	if !errors.Is(err, cause) {
+		t.Error("Expected Wrap to preserve the cause")
+	}
+}
@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyHeader is the request header carrying a client API key.
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyPrefix marks gateway-issued keys, which look like agw_<prefix>_<secret>.
+const apiKeyPrefix = "agw_"
+
+var (
+	// ErrKeyNotFound is returned when no API key matches an ID or prefix.
+	ErrKeyNotFound = errors.New("api key not found")
+	// ErrKeyRevoked is returned when rotating a key that has been revoked.
+	ErrKeyRevoked = errors.New("api key is revoked")
+	// ErrKeyPrefixTaken is returned by KeyStore.Put when another key already
+	// uses the new key's prefix.
+	ErrKeyPrefixTaken = errors.New("api key prefix is taken")
+)
+
+// InvalidScopesError is returned when a key is issued without scopes or with
+// one the gateway does not know.
+type InvalidScopesError struct {
+	Reason string
+}
+
+func (e *InvalidScopesError) Error() string {
+	return e.Reason
+}
+
+// apiKeyPrefixBytes is the number of random bytes in a key's lookup prefix.
+const apiKeyPrefixBytes = 8
+
+// maxKeyGenerationAttempts bounds how often Issue and Rotate draw a new key
+// after a prefix collision.
+const maxKeyGenerationAttempts = 3
+
+// APIKey is the stored record for a client API key. Only a SHA-256 hash of
+// the key is kept; the plaintext is returned once when the key is issued.
+type APIKey struct {
+	ID        string
+	Name      string
+	Prefix    string
+	Hash      string
+	Scopes    []string
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+}
+
+// Active reports whether the key can still be used at time now.
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// KeyStore persists API key records. Keys are looked up by their public
+// prefix so verification never needs to scan every record; Put fails with
+// ErrKeyPrefixTaken rather than repoint a prefix used by another key.
+type KeyStore interface {
+	Put(ctx context.Context, key *APIKey) error
+	Get(ctx context.Context, id string) (*APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	List(ctx context.Context) ([]*APIKey, error)
+}
+
+// MemoryKeyStore is an in-process KeyStore. Keys issued through one process
+// are unknown to every other, so it is only suitable for a single task and tests.
+type MemoryKeyStore struct {
+	mu       sync.RWMutex
+	byID     map[string]*APIKey
+	byPrefix map[string]string
+}
+
+// NewMemoryKeyStore creates an empty in-memory key store.
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{
+		byID:     make(map[string]*APIKey),
+		byPrefix: make(map[string]string),
+	}
+}
+
+// Put inserts or replaces a key, re-indexing its prefix.
+func (s *MemoryKeyStore) Put(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.byPrefix[key.Prefix]; ok && id != key.ID {
+		return ErrKeyPrefixTaken
+	}
+	if old, ok := s.byID[key.ID]; ok {
+		delete(s.byPrefix, old.Prefix)
+	}
+	stored := *key
+	s.byID[key.ID] = &stored
+	s.byPrefix[key.Prefix] = key.ID
+	return nil
+}
+
+// Get returns the key with the given ID.
+func (s *MemoryKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.byID[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	copied := *key
+	return &copied, nil
+}
+
+// GetByPrefix returns the key whose public prefix matches.
+func (s *MemoryKeyStore) GetByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	s.mu.RLock()
+	id, ok := s.byPrefix[prefix]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return s.Get(ctx, id)
+}
+
+// List returns every key ordered by creation time.
+func (s *MemoryKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*APIKey, 0, len(s.byID))
+	for _, key := range s.byID {
+		copied := *key
+		keys = append(keys, &copied)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// KeyManager issues, verifies, rotates and revokes API keys.
+type KeyManager struct {
+	store    KeyStore
+	now      func() time.Time
+	generate func() (plaintext, prefix string, err error)
+}
+
+// NewKeyManager creates a key manager backed by store.
+func NewKeyManager(store KeyStore) *KeyManager {
+	return &KeyManager{store: store, now: time.Now, generate: generateAPIKey}
+}
+
+// Issue creates a new key and returns its plaintext alongside the stored record.
+func (m *KeyManager) Issue(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (string, *APIKey, error) {
+	if err := validateScopes(scopes); err != nil {
+		return "", nil, err
+	}
+
+	key := &APIKey{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: m.now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	plaintext, err := m.putWithNewSecret(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+// List returns every key record.
+func (m *KeyManager) List(ctx context.Context) ([]*APIKey, error) {
+	return m.store.List(ctx)
+}
+
+// Rotate replaces the secret of an existing key, keeping its ID, scopes and
+// expiry. The previous plaintext stops working immediately.
+func (m *KeyManager) Rotate(ctx context.Context, id string) (string, *APIKey, error) {
+	key, err := m.store.Get(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if key.RevokedAt != nil {
+		return "", nil, ErrKeyRevoked
+	}
+
+	plaintext, err := m.putWithNewSecret(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+// putWithNewSecret gives key a freshly generated secret and stores it,
+// drawing again if the prefix collides with another key's.
+func (m *KeyManager) putWithNewSecret(ctx context.Context, key *APIKey) (string, error) {
+	for attempt := 1; ; attempt++ {
+		plaintext, prefix, err := m.generate()
+		if err != nil {
+			return "", err
+		}
+		key.Prefix = prefix
+		key.Hash = hashAPIKey(plaintext)
+		err = m.store.Put(ctx, key)
+		if errors.Is(err, ErrKeyPrefixTaken) && attempt < maxKeyGenerationAttempts {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return plaintext, nil
+	}
+}
+
+// Revoke disables a key permanently.
+func (m *KeyManager) Revoke(ctx context.Context, id string) error {
+	key, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	now := m.now().UTC()
+	key.RevokedAt = &now
+	return m.store.Put(ctx, key)
+}
+
+// Verify returns the active key matching plaintext.
+func (m *KeyManager) Verify(ctx context.Context, plaintext string) (*APIKey, error) {
+	prefix, ok := parseAPIKeyPrefix(plaintext)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	key, err := m.store.GetByPrefix(ctx, prefix)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKey(plaintext))) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	if !key.Active(m.now()) {
+		return nil, fmt.Errorf("%w: api key %s is expired or revoked", ErrInvalidCredentials, key.ID)
+	}
+	return key, nil
+}
+
+// APIKeyAuthenticator authenticates requests carrying an issued key in X-API-Key.
+type APIKeyAuthenticator struct {
+	keys *KeyManager
+}
+
+// NewAPIKeyAuthenticator creates an authenticator that verifies keys with km.
+func NewAPIKeyAuthenticator(km *KeyManager) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: km}
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	plaintext := r.Header.Get(APIKeyHeader)
+	if plaintext == "" || !strings.HasPrefix(plaintext, apiKeyPrefix) {
+		return nil, ErrNoCredentials
+	}
+
+	key, err := a.keys.Verify(r.Context(), plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return newScopedPrincipal("apikey:"+key.ID, "api_key", key.Scopes), nil
+}
+
+// StaticKeyAuthenticator accepts a single shared key, such as the bootstrap
+// admin key loaded from Secrets Manager, and grants it fixed scopes.
+type StaticKeyAuthenticator struct {
+	key    atomic.Pointer[string]
+	id     string
+	scopes []string
+}
+
+// NewStaticKeyAuthenticator creates an authenticator for one shared key.
+// An empty key never matches.
+func NewStaticKeyAuthenticator(id, key string, scopes ...string) *StaticKeyAuthenticator {
+	a := &StaticKeyAuthenticator{id: id, scopes: scopes}
+	a.SetKey(key)
+	return a
+}
+
+// SetKey replaces the shared key, e.g. after it rotates.
+func (a *StaticKeyAuthenticator) SetKey(key string) {
+	a.key.Store(&key)
+}
+
+// Authenticate implements Authenticator.
+func (a *StaticKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	presented, key := r.Header.Get(APIKeyHeader), *a.key.Load()
+	if presented == "" || key == "" || strings.HasPrefix(presented, apiKeyPrefix) {
+		return nil, ErrNoCredentials
+	}
+	if !constantTimeEqual(presented, key) {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{ID: a.id, Method: "static_key", Scopes: a.scopes}, nil
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return &InvalidScopesError{Reason: "at least one scope is required"}
+	}
+	for _, scope := range scopes {
+		if project, ok := strings.CutPrefix(scope, ScopeProjectPrefix); ok && project != "" {
+			continue
+		}
+		if !slices.Contains(KnownScopes, scope) {
+			return &InvalidScopesError{Reason: fmt.Sprintf("unknown scope %q", scope)}
+		}
+	}
+	return nil
+}
+
+func generateAPIKey() (plaintext, prefix string, err error) {
+	buf := make([]byte, apiKeyPrefixBytes+32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("generating api key: %w", err)
+	}
+	prefix = hex.EncodeToString(buf[:apiKeyPrefixBytes])
+	return apiKeyPrefix + prefix + "_" + hex.EncodeToString(buf[apiKeyPrefixBytes:]), prefix, nil
+}
+
+func parseAPIKeyPrefix(plaintext string) (string, bool) {
+	rest, ok := strings.CutPrefix(plaintext, apiKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	return prefix, ok && prefix != "" && secret != ""
+}
+
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+)
+
+// Key records live under one partition so List is a single query; each
+// record's prefix has its own item pointing back at the key ID so
+// verification is a direct read.
+const (
+	apiKeyPartition     = "APIKEY"
+	apiKeyPrefixItemKey = "APIKEYPREFIX#"
+)
+
+// DynamoKeyStore is a KeyStore in the gateway's shared DynamoDB table, so
+// keys issued, rotated or revoked through one task apply to all of them.
+type DynamoKeyStore struct {
+	table *dynamo.Table
+}
+
+// NewDynamoKeyStore creates a key store in table.
+func NewDynamoKeyStore(table *dynamo.Table) *DynamoKeyStore {
+	return &DynamoKeyStore{table: table}
+}
+
+// Put inserts or replaces a key. The record, its prefix item and the removal
+// of a rotated-out prefix are written in one transaction, conditioned on the
+// record not having been re-keyed concurrently.
+func (s *DynamoKeyStore) Put(ctx context.Context, key *APIKey) error {
+	current, err := s.table.Get(ctx, apiKeyPartition, key.ID)
+	if err != nil {
+		return fmt.Errorf("reading api key %s: %w", key.ID, err)
+	}
+
+	record := &types.Put{
+		TableName: s.table.TableName(),
+		Item:      apiKeyItem(key),
+	}
+	if current == nil {
+		record.ConditionExpression = aws.String("attribute_not_exists(pk)")
+	} else {
+		record.ConditionExpression = aws.String("prefix = :prefix")
+		record.ExpressionAttributeValues = map[string]types.AttributeValue{":prefix": current["prefix"]}
+	}
+	items := []types.TransactWriteItem{
+		{Put: record},
+		{Put: &types.Put{
+			TableName: s.table.TableName(),
+			Item: map[string]types.AttributeValue{
+				dynamo.AttrPK: dynamo.S(apiKeyPrefixItemKey + key.Prefix),
+				dynamo.AttrSK: dynamo.S(apiKeyPrefixItemKey),
+				"id":          dynamo.S(key.ID),
+			},
+			ConditionExpression:       aws.String("attribute_not_exists(pk) OR id = :id"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":id": dynamo.S(key.ID)},
+		}},
+	}
+	if old := dynamo.String(current, "prefix"); old != "" && old != key.Prefix {
+		items = append(items, types.TransactWriteItem{Delete: &types.Delete{
+			TableName: s.table.TableName(),
+			Key:       dynamo.Key(apiKeyPrefixItemKey+old, apiKeyPrefixItemKey),
+		}})
+	}
+
+	_, err = s.table.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if dynamo.TransactionConditionFailed(err, 1) {
+		return ErrKeyPrefixTaken
+	}
+	if dynamo.IsConditionFailed(err) {
+		return fmt.Errorf("api key %s changed concurrently", key.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("writing api key %s: %w", key.ID, err)
+	}
+	return nil
+}
+
+// Get returns the key with the given ID.
+func (s *DynamoKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	item, err := s.table.Get(ctx, apiKeyPartition, id)
+	if err != nil {
+		return nil, fmt.Errorf("reading api key %s: %w", id, err)
+	}
+	if item == nil {
+		return nil, ErrKeyNotFound
+	}
+	return apiKeyFromItem(item), nil
+}
+
+// GetByPrefix returns the key whose public prefix matches.
+func (s *DynamoKeyStore) GetByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	item, err := s.table.Get(ctx, apiKeyPrefixItemKey+prefix, apiKeyPrefixItemKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading api key prefix: %w", err)
+	}
+	if item == nil {
+		return nil, ErrKeyNotFound
+	}
+	key, err := s.Get(ctx, dynamo.String(item, "id"))
+	if err != nil {
+		return nil, err
+	}
+	if key.Prefix != prefix {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// List returns every key ordered by creation time.
+func (s *DynamoKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                 s.table.TableName(),
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": dynamo.S(apiKeyPartition)},
+		ConsistentRead:            aws.Bool(true),
+	}
+	var keys []*APIKey
+	for {
+		out, err := s.table.Client.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("listing api keys: %w", err)
+		}
+		for _, item := range out.Items {
+			keys = append(keys, apiKeyFromItem(item))
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// apiKeyItem encodes key as a table item. A key's expiry is stored as
+// keyExpiresAt rather than the table's TTL attribute so expired keys stay
+// listed until revoked.
+func apiKeyItem(key *APIKey) map[string]types.AttributeValue {
+	scopes := make([]types.AttributeValue, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = dynamo.S(scope)
+	}
+	item := map[string]types.AttributeValue{
+		dynamo.AttrPK: dynamo.S(apiKeyPartition),
+		dynamo.AttrSK: dynamo.S(key.ID),
+		"name":        dynamo.S(key.Name),
+		"prefix":      dynamo.S(key.Prefix),
+		"hash":        dynamo.S(key.Hash),
+		"scopes":      &types.AttributeValueMemberL{Value: scopes},
+		"createdAt":   dynamo.Time(key.CreatedAt),
+	}
+	if key.ExpiresAt != nil {
+		item["keyExpiresAt"] = dynamo.Time(*key.ExpiresAt)
+	}
+	if key.RevokedAt != nil {
+		item["revokedAt"] = dynamo.Time(*key.RevokedAt)
+	}
+	return item
+}
+
+func apiKeyFromItem(item map[string]types.AttributeValue) *APIKey {
+	key := &APIKey{
+		ID:        dynamo.String(item, dynamo.AttrSK),
+		Name:      dynamo.String(item, "name"),
+		Prefix:    dynamo.String(item, "prefix"),
+		Hash:      dynamo.String(item, "hash"),
+		CreatedAt: dynamo.TimeValue(item, "createdAt"),
+	}
+	if scopes, ok := item["scopes"].(*types.AttributeValueMemberL); ok {
+		for _, scope := range scopes.Value {
+			if s, ok := scope.(*types.AttributeValueMemberS); ok {
+				key.Scopes = append(key.Scopes, s.Value)
+			}
+		}
+	}
+	key.ExpiresAt = optionalTime(item, "keyExpiresAt")
+	key.RevokedAt = optionalTime(item, "revokedAt")
+	return key
+}
+
+func optionalTime(item map[string]types.AttributeValue, name string) *time.Time {
+	if _, ok := item[name]; !ok {
+		return nil
+	}
+	t := dynamo.TimeValue(item, name)
+	return &t
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo/dynamotest"
+)
+
+func TestDynamoKeyStore_SharedBetweenManagers(t *testing.T) {
+	ctx := context.Background()
+	fake := dynamotest.New()
+	table := dynamo.NewTable(fake, "gateway")
+	// Two tasks behind the load balancer, each with its own manager
+	issuer := NewKeyManager(NewDynamoKeyStore(table))
+	verifier := NewKeyManager(NewDynamoKeyStore(table))
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	plaintext, key, err := issuer.Issue(ctx, "partner", []string{ScopeReelsWrite, ScopeProjectPrefix + "p1"}, &expiresAt)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	verified, err := verifier.Verify(ctx, plaintext)
+	if err != nil {
+		t.Fatalf("Expected key issued by another task to verify, got %v", err)
+	}
+	if verified.ID != key.ID || verified.Name != "partner" || len(verified.Scopes) != 2 || !verified.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected stored record to round-trip, got %+v", verified)
+	}
+
+	rotatedKey, _, err := verifier.Rotate(ctx, key.ID)
+	if err != nil {
+		t.Fatalf("Expected no error rotating, got %v", err)
+	}
+	if _, err := issuer.Verify(ctx, plaintext); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected rotated-out key to fail on every task, got %v", err)
+	}
+	if _, err := issuer.Verify(ctx, rotatedKey); err != nil {
+		t.Errorf("Expected rotated key to verify on every task, got %v", err)
+	}
+	// The record and the current prefix item; the old prefix item is removed
+	if n := fake.Len(); n != 2 {
+		t.Errorf("Expected 2 items after rotation, got %d", n)
+	}
+
+	if err := issuer.Revoke(ctx, key.ID); err != nil {
+		t.Fatalf("Expected no error revoking, got %v", err)
+	}
+	if _, err := verifier.Verify(ctx, rotatedKey); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected revoked key to fail on every task, got %v", err)
+	}
+
+	keys, err := verifier.List(ctx)
+	if err != nil || len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("Expected one revoked key listed, got %v, %v", keys, err)
+	}
+	if err := issuer.Revoke(ctx, "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestDynamoKeyStore_RejectsPrefixCollision(t *testing.T) {
+	ctx := context.Background()
+	store := NewDynamoKeyStore(dynamo.NewTable(dynamotest.New(), "gateway"))
+	km := NewKeyManager(store)
+	_, key, err := km.Issue(ctx, "partner", []string{ScopeRunsRead}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A prefix already indexed for another key must not be taken over
+	other := *key
+	other.ID = "other"
+	if err := store.Put(ctx, &other); !errors.Is(err, ErrKeyPrefixTaken) {
+		t.Errorf("Expected ErrKeyPrefixTaken reusing another key's prefix, got %v", err)
+	}
+}
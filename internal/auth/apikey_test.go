@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo/dynamotest"
+)
+
+func TestKeyManager_IssueAndVerify(t *testing.T) {
+	km := NewKeyManager(NewMemoryKeyStore())
+	ctx := context.Background()
+
+	plaintext, key, err := km.Issue(ctx, "partner", []string{ScopeReelsWrite}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(plaintext, "agw_"+key.Prefix+"_") {
+		t.Errorf("Expected key to start with agw_%s_, got %s", key.Prefix, plaintext)
+	}
+	if key.Hash == "" || strings.Contains(key.Hash, plaintext) {
+		t.Error("Expected only a hash of the key to be stored")
+	}
+
+	verified, err := km.Verify(ctx, plaintext)
+	if err != nil {
+		t.Fatalf("Expected key to verify, got %v", err)
+	}
+	if verified.ID != key.ID {
+		t.Errorf("Expected key %s, got %s", key.ID, verified.ID)
+	}
+
+	// Same prefix, wrong secret
+	if _, err := km.Verify(ctx, "agw_"+key.Prefix+"_deadbeef"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for wrong secret, got %v", err)
+	}
+	if _, err := km.Verify(ctx, "not-a-key"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for malformed key, got %v", err)
+	}
+}
+
+func TestKeyManager_IssueRejectsUnknownScope(t *testing.T) {
+	km := NewKeyManager(NewMemoryKeyStore())
+	ctx := context.Background()
+	var invalid *InvalidScopesError
+	if _, _, err := km.Issue(ctx, "partner", []string{"reels:delete"}, nil); !errors.As(err, &invalid) {
+		t.Errorf("Expected InvalidScopesError for unknown scope, got %v", err)
+	}
+	if _, _, err := km.Issue(ctx, "partner", nil, nil); !errors.As(err, &invalid) {
+		t.Errorf("Expected InvalidScopesError for missing scopes, got %v", err)
+	}
+}
+
+func TestKeyManager_Expiry(t *testing.T) {
+	km := NewKeyManager(NewMemoryKeyStore())
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	plaintext, _, err := km.Issue(ctx, "temp", []string{ScopeRunsRead}, &expiresAt)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := km.Verify(ctx, plaintext); err != nil {
+		t.Fatalf("Expected key to verify before expiry, got %v", err)
+	}
+
+	km.now = func() time.Time { return expiresAt.Add(time.Second) }
+	if _, err := km.Verify(ctx, plaintext); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials after expiry, got %v", err)
+	}
+}
+
+func TestKeyManager_RotateAndRevoke(t *testing.T) {
+	km := NewKeyManager(NewMemoryKeyStore())
+	ctx := context.Background()
+	oldKey, key, _ := km.Issue(ctx, "partner", []string{ScopeReelsWrite}, nil)
+
+	newKey, rotated, err := km.Rotate(ctx, key.ID)
+	if err != nil {
+		t.Fatalf("Expected no error rotating, got %v", err)
+	}
+	if rotated.ID != key.ID || rotated.Prefix == key.Prefix {
+		t.Errorf("Expected same ID with new prefix, got %+v", rotated)
+	}
+	if _, err := km.Verify(ctx, oldKey); err == nil {
+		t.Error("Expected old key to stop working after rotation")
+	}
+	if _, err := km.Verify(ctx, newKey); err != nil {
+		t.Errorf("Expected rotated key to verify, got %v", err)
+	}
+
+	if err := km.Revoke(ctx, key.ID); err != nil {
+		t.Fatalf("Expected no error revoking, got %v", err)
+	}
+	if _, err := km.Verify(ctx, newKey); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials after revoke, got %v", err)
+	}
+	if _, _, err := km.Rotate(ctx, key.ID); !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("Expected ErrKeyRevoked rotating revoked key, got %v", err)
+	}
+	if err := km.Revoke(ctx, "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestKeyManager_RetriesPrefixCollisions(t *testing.T) {
+	stores := map[string]func() KeyStore{
+		"memory": func() KeyStore { return NewMemoryKeyStore() },
+		"dynamo": func() KeyStore { return NewDynamoKeyStore(dynamo.NewTable(dynamotest.New(), "gateway")) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			km := NewKeyManager(newStore())
+			// The second key collides twice before drawing "bbbb"; its rotation
+			// collides on every attempt
+			prefixes := []string{"aaaa", "aaaa", "aaaa", "bbbb", "aaaa", "aaaa", "aaaa"}
+			var draws int
+			km.generate = func() (string, string, error) {
+				prefix := prefixes[draws]
+				draws++
+				return fmt.Sprintf("agw_%s_secret%d", prefix, draws), prefix, nil
+			}
+
+			first, key, err := km.Issue(ctx, "first", []string{ScopeRunsRead}, nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			second, other, err := km.Issue(ctx, "second", []string{ScopeRunsRead}, nil)
+			if err != nil || other.Prefix != "bbbb" {
+				t.Fatalf("Expected a retried prefix, got %+v, %v", other, err)
+			}
+			for _, plaintext := range []string{first, second} {
+				if _, err := km.Verify(ctx, plaintext); err != nil {
+					t.Errorf("Expected %s to verify, got %v", plaintext, err)
+				}
+			}
+
+			// Rotation gives up after repeated collisions and leaves the key unchanged
+			if _, _, err := km.Rotate(ctx, other.ID); !errors.Is(err, ErrKeyPrefixTaken) {
+				t.Errorf("Expected ErrKeyPrefixTaken after repeated collisions, got %v", err)
+			}
+			if _, err := km.Verify(ctx, second); err != nil {
+				t.Errorf("Expected the unrotated key to keep working, got %v", err)
+			}
+			if _, err := km.Verify(ctx, first); err != nil || key.Prefix != "aaaa" {
+				t.Errorf("Expected the first key to keep its prefix, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateAPIKey_PrefixLength(t *testing.T) {
+	plaintext, prefix, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(prefix) != 2*apiKeyPrefixBytes {
+		t.Errorf("Expected a %d-character prefix, got %q", 2*apiKeyPrefixBytes, prefix)
+	}
+	if got, ok := parseAPIKeyPrefix(plaintext); !ok || got != prefix {
+		t.Errorf("Expected prefix %q to parse back, got %q", prefix, got)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	km := NewKeyManager(NewMemoryKeyStore())
+	ctx := context.Background()
+	writer, _, _ := km.Issue(ctx, "writer", []string{ScopeReelsWrite}, nil)
+	reader, _, _ := km.Issue(ctx, "reader", []string{ScopeRunsRead}, nil)
+	authn := Chain{
+		NewAPIKeyAuthenticator(km),
+		NewStaticKeyAuthenticator("admin", "bootstrap-secret", ScopeKeysAdmin),
+	}
+
+	var seen *Principal
+	handler := Require(authn, ScopeReelsWrite, func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	tests := []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{name: "no key", key: "", status: http.StatusUnauthorized},
+		{name: "unknown key", key: "agw_00000000_00", status: http.StatusUnauthorized},
+		{name: "wrong static key", key: "guess", status: http.StatusUnauthorized},
+		{name: "missing scope", key: reader, status: http.StatusForbidden},
+		{name: "admin key lacks reels scope", key: "bootstrap-secret", status: http.StatusForbidden},
+		{name: "authorized", key: writer, status: http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/reels", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+
+	if seen == nil || seen.Method != "api_key" || !seen.HasScope(ScopeReelsWrite) {
+		t.Errorf("Expected api_key principal in context, got %+v", seen)
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ClientCertIdentity grants scopes to callers presenting a verified client
+// certificate for Subject, matched against the certificate's common name,
+// DNS SANs and URI SANs (e.g. a SPIFFE ID).
+type ClientCertIdentity struct {
+	Subject string   `json:"subject"`
+	Scopes  []string `json:"scopes"`
+}
+
+// ParseClientCertIdentities decodes a JSON array of identities. An empty
+// document yields no identities.
+func ParseClientCertIdentities(data string) ([]ClientCertIdentity, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var identities []ClientCertIdentity
+	if err := json.Unmarshal([]byte(data), &identities); err != nil {
+		return nil, fmt.Errorf("decoding client certificate identities: %w", err)
+	}
+	for _, id := range identities {
+		if id.Subject == "" {
+			return nil, errors.New("client certificate identities require a subject")
+		}
+	}
+	return identities, nil
+}
+
+// ClientCertAuthenticator maps client certificates verified by the TLS
+// handshake to principals. The TLS layer does chain verification; this only
+// decides which verified subjects are known.
+type ClientCertAuthenticator struct {
+	identities map[string]ClientCertIdentity
+}
+
+// NewClientCertAuthenticator creates an authenticator for identities.
+func NewClientCertAuthenticator(identities []ClientCertIdentity) *ClientCertAuthenticator {
+	byName := make(map[string]ClientCertIdentity, len(identities))
+	for _, id := range identities {
+		byName[id.Subject] = id
+	}
+	return &ClientCertAuthenticator{identities: byName}
+}
+
+// Authenticate implements Authenticator.
+func (a *ClientCertAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	for _, name := range certificateNames(leaf) {
+		if id, ok := a.identities[name]; ok {
+			return newScopedPrincipal("mtls:"+id.Subject, "client_cert", id.Scopes), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown client certificate %q", ErrInvalidCredentials, leaf.Subject.CommonName)
+}
+
+// certificateNames lists the names a certificate can be matched by.
+func certificateNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	return names
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClientCertAuthenticator(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/orchestrator")
+	a := NewClientCertAuthenticator([]ClientCertIdentity{
+		{Subject: "partner-a", Scopes: []string{ScopeReelsWrite, "project:proj_1"}},
+		{Subject: "spiffe://example.org/orchestrator", Scopes: []string{ScopeRunsRead}},
+	})
+
+	withCert := func(cert *x509.Certificate) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/runs/run_1", nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		wantID  string
+		wantErr error
+	}{
+		{name: "common name", request: withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "partner-a"}}), wantID: "mtls:partner-a"},
+		{name: "uri san", request: withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "orchestrator"}, URIs: []*url.URL{spiffe}}), wantID: "mtls:spiffe://example.org/orchestrator"},
+		{name: "unknown subject", request: withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "partner-b"}}), wantErr: ErrInvalidCredentials},
+		{name: "plain http", request: httptest.NewRequest(http.MethodGet, "/runs/run_1", nil), wantErr: ErrNoCredentials},
+		{name: "no client certificate", request: func() *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/runs/run_1", nil)
+			req.TLS = &tls.ConnectionState{}
+			return req
+		}(), wantErr: ErrNoCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.Authenticate(tt.request)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.ID != tt.wantID || p.Method != "client_cert" {
+				t.Errorf("Expected %s via client_cert, got %s via %s", tt.wantID, p.ID, p.Method)
+			}
+		})
+	}
+}
+
+func TestParseClientCertIdentities(t *testing.T) {
+	ids, err := ParseClientCertIdentities(`[{"subject":"partner-a","scopes":["reels:write"]}]`)
+	if err != nil || len(ids) != 1 || ids[0].Subject != "partner-a" {
+		t.Fatalf("Unexpected result %+v, %v", ids, err)
+	}
+	if ids, err := ParseClientCertIdentities(""); err != nil || ids != nil {
+		t.Errorf("Expected no identities for empty input, got %+v, %v", ids, err)
+	}
+	if _, err := ParseClientCertIdentities(`[{"scopes":["reels:write"]}]`); err == nil {
+		t.Error("Expected error for missing subject")
+	}
+}
@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HMAC request signing, modelled on AWS SigV4. A signed request carries:
+//
+//	X-Agw-Date:    20060102T150405Z
+//	X-Agw-Nonce:   <unique per request>
+//	Authorization: AGW-HMAC-SHA256 Credential=<clientId>, SignedHeaders=host;x-agw-date;x-agw-nonce, Signature=<hex>
+//
+// The signature is hex(HMAC-SHA256(secret, StringToSign)) where
+//
+//	StringToSign     = "AGW-HMAC-SHA256\n" + date + "\n" + nonce + "\n" + hex(SHA256(CanonicalRequest))
+//	CanonicalRequest = method + "\n" + path + "\n" + sorted query + "\n" +
+//	                   canonical headers + "\n" + signed headers + "\n" + hex(SHA256(body))
+const (
+	HMACAlgorithm   = "AGW-HMAC-SHA256"
+	HMACDateHeader  = "X-Agw-Date"
+	HMACNonceHeader = "X-Agw-Nonce"
+	hmacDateFormat  = "20060102T150405Z"
+
+	// maxNonceLength bounds the nonces remembered per request.
+	maxNonceLength = 128
+)
+
+// hmacRequiredHeaders must always be covered by the signature.
+var hmacRequiredHeaders = []string{"host", "x-agw-date", "x-agw-nonce"}
+
+// HMACClient is a server-to-server caller that signs requests with a shared secret.
+type HMACClient struct {
+	ID     string   `json:"id"`
+	Secret string   `json:"secret"`
+	Scopes []string `json:"scopes"`
+}
+
+// ParseHMACClients decodes the hmac-clients secret, a JSON array of clients.
+// An empty document yields no clients.
+func ParseHMACClients(data string) ([]HMACClient, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var clients []HMACClient
+	if err := json.Unmarshal([]byte(data), &clients); err != nil {
+		return nil, fmt.Errorf("decoding hmac clients: %w", err)
+	}
+	for _, c := range clients {
+		if c.ID == "" || c.Secret == "" {
+			return nil, errors.New("hmac clients require an id and secret")
+		}
+	}
+	return clients, nil
+}
+
+// NonceStore remembers the nonces of verified requests so replays can be rejected.
+type NonceStore interface {
+	// Add records nonce until expiresAt and reports whether it was unseen.
+	Add(ctx context.Context, nonce string, expiresAt, now time.Time) (bool, error)
+}
+
+// HMACOptions configures an HMACAuthenticator.
+type HMACOptions struct {
+	// MaxSkew is how far a request's date may be from the server clock.
+	MaxSkew time.Duration
+	// MaxBodyBytes is the largest body buffered for hashing, normally the
+	// server's request body limit.
+	MaxBodyBytes int64
+	// Nonces remembers used nonces. The default NonceCache only sees requests
+	// to this process, so a replay sent to another task is accepted within
+	// MaxSkew; deployments with several tasks use a shared store.
+	Nonces NonceStore
+}
+
+// HMACAuthenticator verifies signed requests, their clock skew and nonce uniqueness.
+type HMACAuthenticator struct {
+	clients atomic.Pointer[map[string]HMACClient]
+	opts    HMACOptions
+	now     func() time.Time
+}
+
+// NewHMACAuthenticator creates an authenticator for clients. Zero options
+// default to a 5 minute skew, a 1 MiB body and an in-process NonceCache.
+func NewHMACAuthenticator(clients []HMACClient, opts HMACOptions) *HMACAuthenticator {
+	if opts.MaxSkew <= 0 {
+		opts.MaxSkew = 5 * time.Minute
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+	if opts.Nonces == nil {
+		opts.Nonces = NewNonceCache()
+	}
+	a := &HMACAuthenticator{opts: opts, now: time.Now}
+	a.SetClients(clients)
+	return a
+}
+
+// SetClients replaces the known clients, e.g. after the hmac-clients secret rotates.
+func (a *HMACAuthenticator) SetClients(clients []HMACClient) {
+	byID := make(map[string]HMACClient, len(clients))
+	for _, c := range clients {
+		byID[c.ID] = c
+	}
+	a.clients.Store(&byID)
+}
+
+// Authenticate implements Authenticator.
+func (a *HMACAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	params, ok := parseHMACAuthorization(r.Header.Get("Authorization"))
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	client, ok := (*a.clients.Load())[params.credential]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown hmac client %q", ErrInvalidCredentials, params.credential)
+	}
+	for _, h := range hmacRequiredHeaders {
+		if !slices.Contains(params.signedHeaders, h) {
+			return nil, fmt.Errorf("%w: %s must be signed", ErrInvalidCredentials, h)
+		}
+	}
+
+	date, err := time.Parse(hmacDateFormat, r.Header.Get(HMACDateHeader))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s", ErrInvalidCredentials, HMACDateHeader)
+	}
+	now := a.now()
+	if date.Before(now.Add(-a.opts.MaxSkew)) || date.After(now.Add(a.opts.MaxSkew)) {
+		return nil, fmt.Errorf("%w: request date outside allowed clock skew", ErrInvalidCredentials)
+	}
+	nonce := r.Header.Get(HMACNonceHeader)
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return nil, fmt.Errorf("%w: missing or overlong %s", ErrInvalidCredentials, HMACNonceHeader)
+	}
+
+	body, err := readAndRestoreBody(r, a.opts.MaxBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	expected := hmacSignature(client.Secret, stringToSign(r, params.signedHeaders, body))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(params.signature))) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidCredentials)
+	}
+
+	// Only remember nonces of verified requests, for as long as their date is acceptable
+	fresh, err := a.opts.Nonces.Add(r.Context(), client.ID+"/"+nonce, date.Add(a.opts.MaxSkew), now)
+	if err != nil {
+		return nil, fmt.Errorf("checking nonce: %w", err)
+	}
+	if !fresh {
+		return nil, fmt.Errorf("%w: replayed nonce", ErrInvalidCredentials)
+	}
+
+	return newScopedPrincipal("hmac:"+client.ID, "hmac", client.Scopes), nil
+}
+
+// SignRequest signs r for clientID, setting the date, nonce and Authorization
+// headers. It is what HMAC clients (and tests) use to call the gateway.
+func SignRequest(r *http.Request, clientID, secret, nonce string, now time.Time) error {
+	r.Header.Set(HMACDateHeader, now.UTC().Format(hmacDateFormat))
+	r.Header.Set(HMACNonceHeader, nonce)
+
+	body, err := readAndRestoreBody(r, 0)
+	if err != nil {
+		return err
+	}
+	signature := hmacSignature(secret, stringToSign(r, hmacRequiredHeaders, body))
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s, SignedHeaders=%s, Signature=%s",
+		HMACAlgorithm, clientID, strings.Join(hmacRequiredHeaders, ";"), signature))
+	return nil
+}
+
+type hmacParams struct {
+	credential    string
+	signedHeaders []string
+	signature     string
+}
+
+func parseHMACAuthorization(header string) (hmacParams, bool) {
+	rest, ok := strings.CutPrefix(header, HMACAlgorithm+" ")
+	if !ok {
+		return hmacParams{}, false
+	}
+
+	var p hmacParams
+	for _, part := range strings.Split(rest, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "Credential":
+			p.credential = value
+		case "SignedHeaders":
+			p.signedHeaders = strings.Split(strings.ToLower(value), ";")
+		case "Signature":
+			p.signature = value
+		}
+	}
+	return p, p.credential != "" && p.signature != "" && len(p.signedHeaders) > 0
+}
+
+func stringToSign(r *http.Request, signedHeaders []string, body []byte) string {
+	return strings.Join([]string{
+		HMACAlgorithm,
+		r.Header.Get(HMACDateHeader),
+		r.Header.Get(HMACNonceHeader),
+		sha256Hex([]byte(canonicalRequest(r, signedHeaders, body))),
+	}, "\n")
+}
+
+func canonicalRequest(r *http.Request, signedHeaders []string, body []byte) string {
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		path,
+		canonicalQuery(r.URL.Query()),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		sha256Hex(body),
+	}, "\n")
+}
+
+func canonicalQuery(values url.Values) string {
+	pairs := make([]string, 0, len(values))
+	for key, vals := range values {
+		for _, v := range vals {
+			pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func hmacSignature(secret, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readAndRestoreBody reads the request body and replaces it so the handler
+// can read it again. A body over maxBytes (when positive) fails with
+// *http.MaxBytesError.
+func readAndRestoreBody(r *http.Request, maxBytes int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	reader := io.Reader(r.Body)
+	if maxBytes > 0 {
+		reader = io.LimitReader(r.Body, maxBytes+1)
+	}
+	body, err := io.ReadAll(reader)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if maxBytes > 0 && int64(len(body)) > maxBytes {
+		return nil, &http.MaxBytesError{Limit: maxBytes}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// NonceCache is an in-process NonceStore.
+type NonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewNonceCache creates an empty nonce cache.
+func NewNonceCache() *NonceCache {
+	return &NonceCache{seen: make(map[string]time.Time)}
+}
+
+// Add records nonce until expiresAt and reports whether it was unseen.
+// Expired entries are swept lazily, so no background goroutine is needed.
+func (c *NonceCache) Add(ctx context.Context, nonce string, expiresAt, now time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.After(c.nextSweep) {
+		for n, exp := range c.seen {
+			if now.After(exp) {
+				delete(c.seen, n)
+			}
+		}
+		c.nextSweep = now.Add(time.Minute)
+	}
+
+	if exp, ok := c.seen[nonce]; ok && !now.After(exp) {
+		return false, nil
+	}
+	c.seen[nonce] = expiresAt
+	return true, nil
+}
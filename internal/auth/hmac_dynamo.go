@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+)
+
+// nonceKeyPrefix starts the partition key of nonce items, NONCE#<clientId>/<nonce>.
+const nonceKeyPrefix = "NONCE#"
+
+// DynamoNonceStore is a NonceStore in the gateway's shared DynamoDB table,
+// so a nonce used against one task is rejected by every other.
+type DynamoNonceStore struct {
+	table *dynamo.Table
+}
+
+// NewDynamoNonceStore creates a nonce store in table.
+func NewDynamoNonceStore(table *dynamo.Table) *DynamoNonceStore {
+	return &DynamoNonceStore{table: table}
+}
+
+// Add implements NonceStore with a conditional put that succeeds only when
+// the nonce is unseen or its previous use has expired.
+func (s *DynamoNonceStore) Add(ctx context.Context, nonce string, expiresAt, now time.Time) (bool, error) {
+	_, err := s.table.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: s.table.TableName(),
+		Item: map[string]types.AttributeValue{
+			dynamo.AttrPK:        dynamo.S(nonceKeyPrefix + nonce),
+			dynamo.AttrSK:        dynamo.S(nonceKeyPrefix),
+			dynamo.AttrExpiresAt: dynamo.ExpiresAt(expiresAt),
+		},
+		ConditionExpression:       aws.String("attribute_not_exists(pk) OR expiresAt < :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":now": dynamo.N(now.Unix())},
+	})
+	if dynamo.IsConditionFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("recording nonce: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo/dynamotest"
+)
+
+func newSignedRequest(t *testing.T, body, nonce string, at time.Time) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/reels?dryRun=true&b=2", strings.NewReader(body))
+	if err := SignRequest(req, "partner-a", "partner-secret", nonce, at); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	return req
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	newAuthn := func() *HMACAuthenticator {
+		a := NewHMACAuthenticator([]HMACClient{{ID: "partner-a", Secret: "partner-secret", Scopes: []string{ScopeReelsWrite, "project:proj_1"}}}, HMACOptions{MaxSkew: 5 * time.Minute})
+		a.now = func() time.Time { return now }
+		return a
+	}
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		wantErr error
+	}{
+		{name: "valid", request: func() *http.Request { return newSignedRequest(t, `{"projectId":"proj_1"}`, "n-1", now) }},
+		{name: "not signed", request: func() *http.Request { return httptest.NewRequest(http.MethodPost, "/reels", nil) }, wantErr: ErrNoCredentials},
+		{name: "tampered body", request: func() *http.Request {
+			req := newSignedRequest(t, `{"projectId":"proj_1"}`, "n-2", now)
+			req.Body = io.NopCloser(strings.NewReader(`{"projectId":"proj_2"}`))
+			return req
+		}, wantErr: ErrInvalidCredentials},
+		{name: "tampered query", request: func() *http.Request {
+			req := newSignedRequest(t, "", "n-3", now)
+			req.URL.RawQuery = "dryRun=false"
+			return req
+		}, wantErr: ErrInvalidCredentials},
+		{name: "clock skew", request: func() *http.Request { return newSignedRequest(t, "", "n-4", now.Add(-6*time.Minute)) }, wantErr: ErrInvalidCredentials},
+		{name: "unknown client", request: func() *http.Request {
+			req := newSignedRequest(t, "", "n-5", now)
+			req.Header.Set("Authorization", strings.Replace(req.Header.Get("Authorization"), "partner-a", "partner-b", 1))
+			return req
+		}, wantErr: ErrInvalidCredentials},
+		{name: "overlong nonce", request: func() *http.Request { return newSignedRequest(t, "", strings.Repeat("n", 129), now) }, wantErr: ErrInvalidCredentials},
+		{name: "nonce not signed", request: func() *http.Request {
+			req := newSignedRequest(t, "", "n-6", now)
+			req.Header.Set("Authorization", strings.Replace(req.Header.Get("Authorization"), ";x-agw-nonce", "", 1))
+			return req
+		}, wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.request()
+			p, err := newAuthn().Authenticate(req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected request to verify, got %v", err)
+			}
+			if p.ID != "hmac:partner-a" || !p.HasScope(ScopeReelsWrite) || !p.CanAccessProject("proj_1") {
+				t.Errorf("Unexpected principal %+v", p)
+			}
+
+			// The handler can still read the body
+			body, _ := io.ReadAll(req.Body)
+			if string(body) != `{"projectId":"proj_1"}` {
+				t.Errorf("Expected body to be restored, got %q", body)
+			}
+		})
+	}
+}
+
+func TestHMACAuthenticator_RejectsReplay(t *testing.T) {
+	clients := []HMACClient{{ID: "partner-a", Secret: "partner-secret"}}
+	local := NewHMACAuthenticator(clients, HMACOptions{MaxSkew: time.Minute})
+	shared := NewDynamoNonceStore(dynamo.NewTable(dynamotest.New(), "gateway"))
+	tests := []struct {
+		name string
+		// first and second serve the original request and its replay
+		first, second *HMACAuthenticator
+	}{
+		{name: "same task", first: local, second: local},
+		{name: "other task, shared store",
+			first:  NewHMACAuthenticator(clients, HMACOptions{MaxSkew: time.Minute, Nonces: shared}),
+			second: NewHMACAuthenticator(clients, HMACOptions{MaxSkew: time.Minute, Nonces: shared})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			if _, err := tt.first.Authenticate(newSignedRequest(t, "{}", "nonce-1", now)); err != nil {
+				t.Fatalf("Expected first request to verify, got %v", err)
+			}
+			if _, err := tt.second.Authenticate(newSignedRequest(t, "{}", "nonce-1", now)); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Expected replay to be rejected, got %v", err)
+			}
+			if _, err := tt.second.Authenticate(newSignedRequest(t, "{}", "nonce-2", now)); err != nil {
+				t.Errorf("Expected fresh nonce to verify, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHMACAuthenticator_BodyLimit(t *testing.T) {
+	authn := NewHMACAuthenticator([]HMACClient{{ID: "partner-a", Secret: "partner-secret"}}, HMACOptions{MaxBodyBytes: 16})
+	handler := Require(authn, "", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) })
+
+	rec := httptest.NewRecorder()
+	handler(rec, newSignedRequest(t, `{"projectId":"proj_1"}`, "n-1", time.Now()))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d for a body over the limit, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, newSignedRequest(t, `{}`, "n-2", time.Now()))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Expected status %d within the limit, got %d", http.StatusAccepted, rec.Code)
+	}
+}
+
+func TestNonceStores_Expiry(t *testing.T) {
+	stores := map[string]NonceStore{
+		"memory": NewNonceCache(),
+		"dynamo": NewDynamoNonceStore(dynamo.NewTable(dynamotest.New(), "gateway")),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+
+			if ok, err := s.Add(ctx, "a", now.Add(time.Minute), now); !ok || err != nil {
+				t.Fatalf("Expected first add to succeed, got %v, %v", ok, err)
+			}
+			if ok, _ := s.Add(ctx, "a", now.Add(time.Minute), now.Add(30*time.Second)); ok {
+				t.Error("Expected duplicate within expiry to be rejected")
+			}
+			if ok, err := s.Add(ctx, "a", now.Add(3*time.Minute), now.Add(2*time.Minute)); !ok || err != nil {
+				t.Errorf("Expected nonce to be accepted again after expiry, got %v, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestParseHMACClients(t *testing.T) {
+	clients, err := ParseHMACClients(`[{"id":"partner-a","secret":"s","scopes":["reels:write"]}]`)
+	if err != nil || len(clients) != 1 || clients[0].Scopes[0] != ScopeReelsWrite {
+		t.Fatalf("Unexpected result %+v, %v", clients, err)
+	}
+	if clients, err := ParseHMACClients(""); err != nil || clients != nil {
+		t.Errorf("Expected no clients for empty secret, got %+v, %v", clients, err)
+	}
+	if _, err := ParseHMACClients(`[{"id":"partner-a"}]`); err == nil {
+		t.Error("Expected error for client without secret")
+	}
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/logging"
+)
+
+// ErrUnknownKey is returned when a JWKS has no key with the requested kid.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// JWKSOptions tunes how a JWKS document is cached.
+type JWKSOptions struct {
+	// MaxAge is how long a fetched document is trusted before it is refetched.
+	MaxAge time.Duration
+	// MinRefreshInterval bounds how often an unknown kid may trigger a refetch.
+	MinRefreshInterval time.Duration
+	HTTPClient         *http.Client
+}
+
+// JWKS fetches and caches the public keys of an external identity provider.
+// The source is an http(s) URL or, for tests and air-gapped setups, a
+// file:// URL or plain path.
+type JWKS struct {
+	source string
+	opts   JWKSOptions
+	now    func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// refreshing is the fetch in flight, shared by every caller that needs it.
+	refreshing *jwksRefresh
+}
+
+// jwksRefresh is one fetch of the document; done is closed once it finishes.
+type jwksRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWKS creates a key set for source. Keys are fetched lazily on first use.
+func NewJWKS(source string, opts JWKSOptions) *JWKS {
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = time.Hour
+	}
+	if opts.MinRefreshInterval <= 0 {
+		opts.MinRefreshInterval = 30 * time.Second
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWKS{source: source, opts: opts, now: time.Now}
+}
+
+// Key returns the public key for kid, refetching the document when it is
+// stale or when kid is unknown (at most once per MinRefreshInterval).
+// Concurrent callers share a single fetch, which runs without holding the
+// cache lock so cached keys stay available while it is in flight.
+func (j *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	now := j.now()
+	stale := j.keys == nil || now.Sub(j.fetchedAt) >= j.opts.MaxAge
+	if key, ok := j.keys[kid]; ok && !stale {
+		j.mu.Unlock()
+		return key, nil
+	}
+
+	refresh := j.refreshing
+	if refresh == nil {
+		if now.Sub(j.lastAttempt) < j.opts.MinRefreshInterval {
+			defer j.mu.Unlock()
+			return j.cached(kid, nil)
+		}
+		j.lastAttempt = now
+		refresh = &jwksRefresh{done: make(chan struct{})}
+		j.refreshing = refresh
+		// The fetch outlives a caller that gives up, so the others still get its result
+		go j.refresh(context.WithoutCancel(ctx), refresh, now)
+	}
+	j.mu.Unlock()
+
+	select {
+	case <-refresh.done:
+	case <-ctx.Done():
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		return j.cached(kid, ctx.Err())
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.cached(kid, refresh.err)
+}
+
+// refresh fetches the document and, if it is usable, replaces the cached keys.
+func (j *JWKS) refresh(ctx context.Context, refresh *jwksRefresh, startedAt time.Time) {
+	keys, err := j.fetch(ctx)
+
+	j.mu.Lock()
+	if err == nil {
+		j.keys = keys
+		j.fetchedAt = startedAt
+	}
+	refresh.err = err
+	j.refreshing = nil
+	j.mu.Unlock()
+	close(refresh.done)
+}
+
+// cached returns the cached key for kid. Without one it reports refreshErr,
+// the failure of the latest refresh, or ErrUnknownKey. j.mu must be held.
+func (j *JWKS) cached(kid string, refreshErr error) (crypto.PublicKey, error) {
+	// Keep serving the previous keys if the provider is briefly unavailable
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	if refreshErr != nil {
+		return nil, fmt.Errorf("refreshing JWKS from %s: %w", j.source, refreshErr)
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+}
+
+func (j *JWKS) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(j.source, "http://") || strings.HasPrefix(j.source, "https://") {
+		data, err = j.fetchHTTP(ctx)
+	} else {
+		data, err = os.ReadFile(strings.TrimPrefix(j.source, "file://"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(ctx, data)
+}
+
+func (j *JWKS) fetchHTTP(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := j.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// jsonWebKey is the subset of RFC 7517 fields needed for RSA and EC keys.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS decodes the signing keys of a JWKS document. Keys of an
+// unsupported type or curve, or that are malformed, are logged and skipped so
+// one bad entry does not take down the rest; it fails only when no usable key
+// remains.
+func parseJWKS(ctx context.Context, data []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	var skipped []error
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			logging.FromContext(ctx).Warn("Skipping unusable JWKS key", "kid", jwk.Kid, logging.KeyError, err)
+			skipped = append(skipped, fmt.Errorf("key %q: %w", jwk.Kid, err))
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		if len(skipped) > 0 {
+			return nil, fmt.Errorf("JWKS has no usable signing keys: %w", errors.Join(skipped...))
+		}
+		return nil, errors.New("JWKS has no signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if n.Sign() == 0 {
+			return nil, errors.New("missing RSA modulus")
+		}
+		// crypto/rsa only accepts exponents from 2 to 2^31-1
+		if e.Cmp(big.NewInt(2)) < 0 || e.Cmp(big.NewInt(math.MaxInt32)) > 0 {
+			return nil, fmt.Errorf("invalid RSA exponent %s", e)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if _, err := key.ECDH(); err != nil {
+			return nil, err
+		}
+		return key, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,337 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) jsonWebKey {
+	return jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig", N: b64(key.N), E: b64(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) jsonWebKey {
+	return jsonWebKey{Kty: "EC", Kid: kid, Crv: "P-256", X: b64(key.X), Y: b64(key.Y)}
+}
+
+func jwksDocument(t *testing.T, keys ...jsonWebKey) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatalf("Failed to marshal JWKS: %v", err)
+	}
+	return data
+}
+
+func signExternal(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims ExternalClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func externalClaims(issuer, audience string) ExternalClaims {
+	return ExternalClaims{
+		Scope:    ScopeRunsRead,
+		Projects: []string{"proj_1"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   "user-1",
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+}
+
+func TestExternalTokenAuthenticator_FileJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksDocument(t, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey)), 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+
+	const issuer, audience = "https://idp.example.com/", "api-gateway"
+	authn := NewExternalTokenAuthenticator(NewJWKS("file://"+path, JWKSOptions{}), issuer, audience)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "RS256", token: signExternal(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, externalClaims(issuer, audience))},
+		{name: "ES256", token: signExternal(t, jwt.SigningMethodES256, "ec-1", ecKey, externalClaims(issuer, audience))},
+		{name: "wrong audience", token: signExternal(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, externalClaims(issuer, "someone-else")), wantErr: ErrInvalidCredentials},
+		{name: "other issuer", token: signExternal(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, externalClaims("https://other.example.com/", audience)), wantErr: ErrNoCredentials},
+		{name: "kid mismatch", token: signExternal(t, jwt.SigningMethodRS256, "ec-1", rsaKey, externalClaims(issuer, audience)), wantErr: ErrInvalidCredentials},
+		{name: "unknown kid", token: signExternal(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, externalClaims(issuer, audience)), wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/runs/abc", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			p, err := authn.Authenticate(req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected token to verify, got %v", err)
+			}
+			if p.ID != "user:user-1" || !p.HasScope(ScopeRunsRead) {
+				t.Errorf("Unexpected principal %+v", p)
+			}
+			if !p.CanAccessProject("proj_1") || p.CanAccessProject("proj_2") {
+				t.Errorf("Expected access to proj_1 only, got %+v", p)
+			}
+		})
+	}
+}
+
+func TestExternalTokenAuthenticator_AdminRole(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(path, jwksDocument(t, rsaJWK("rsa-1", &key.PublicKey)), 0o600)
+
+	authn := NewExternalTokenAuthenticator(NewJWKS(path, JWKSOptions{}), "https://idp.example.com/", "")
+	claims := externalClaims("https://idp.example.com/", "anything")
+	claims.Projects = nil
+	claims.Roles = []string{RoleAdmin}
+
+	req := httptest.NewRequest(http.MethodGet, "/runs/abc", nil)
+	req.Header.Set("Authorization", "Bearer "+signExternal(t, jwt.SigningMethodRS256, "rsa-1", key, claims))
+	p, err := authn.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Expected token to verify, got %v", err)
+	}
+	if !p.Admin || !p.CanAccessProject("any-project") {
+		t.Errorf("Expected admin principal, got %+v", p)
+	}
+}
+
+func TestExternalTokenAuthenticator_StringScp(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(path, jwksDocument(t, rsaJWK("rsa-1", &key.PublicKey)), 0o600)
+
+	authn := NewExternalTokenAuthenticator(NewJWKS(path, JWKSOptions{}), "https://idp.example.com/", "")
+	// Azure AD sends scp as a space-delimited string
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": "https://idp.example.com/",
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Minute).Unix(),
+		"scp": ScopeReelsWrite + " " + ScopeRunsRead,
+	})
+	token.Header["kid"] = "rsa-1"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/runs/abc", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	p, err := authn.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Expected token to verify, got %v", err)
+	}
+	if !p.HasScope(ScopeReelsWrite) || !p.HasScope(ScopeRunsRead) {
+		t.Errorf("Expected both scopes from the scp string, got %+v", p)
+	}
+}
+
+func TestScopeList_UnmarshalJSON(t *testing.T) {
+	tests := map[string][]string{
+		`"reels:write  runs:read"`:    {"reels:write", "runs:read"},
+		`["reels:write","runs:read"]`: {"reels:write", "runs:read"},
+		`""`:                          {},
+	}
+	for input, want := range tests {
+		var got ScopeList
+		if err := json.Unmarshal([]byte(input), &got); err != nil || len(got) != len(want) || (len(want) > 0 && got[1] != want[1]) {
+			t.Errorf("Unmarshal(%s) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	var got ScopeList
+	if err := json.Unmarshal([]byte(`42`), &got); err == nil {
+		t.Error("Expected an error for a non-string, non-array scp")
+	}
+}
+
+func TestJWKS_RefreshOnUnknownKidIsRateLimited(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var fetches atomic.Int32
+	var doc atomic.Value
+	doc.Store(jwksDocument(t, rsaJWK("old", &oldKey.PublicKey)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(doc.Load().([]byte))
+	}))
+	defer server.Close()
+
+	jwks := NewJWKS(server.URL, JWKSOptions{MinRefreshInterval: time.Minute})
+	now := time.Now()
+	jwks.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := jwks.Key(ctx, "old"); err != nil {
+		t.Fatalf("Expected old key, got %v", err)
+	}
+
+	// The provider rotates; unknown kids inside the interval do not refetch
+	doc.Store(jwksDocument(t, rsaJWK("old", &oldKey.PublicKey), rsaJWK("new", &newKey.PublicKey)))
+	for i := 0; i < 3; i++ {
+		if _, err := jwks.Key(ctx, "new"); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("Expected ErrUnknownKey inside refresh interval, got %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("Expected 1 fetch inside refresh interval, got %d", got)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := jwks.Key(ctx, "new"); err != nil {
+		t.Fatalf("Expected rotated key after interval, got %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("Expected 2 fetches, got %d", got)
+	}
+
+	// Known kids are served from cache
+	if _, err := jwks.Key(ctx, "old"); err != nil {
+		t.Fatalf("Expected cached key, got %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("Expected cached lookup not to fetch, got %d fetches", got)
+	}
+}
+
+func TestParseJWKS_RejectsInvalidKeys(t *testing.T) {
+	tests := map[string]jsonWebKey{
+		"unsupported kty":   {Kty: "oct", Kid: "k"},
+		"unsupported crv":   {Kty: "EC", Kid: "k", Crv: "P-192", X: "AA", Y: "AA"},
+		"point off curve":   {Kty: "EC", Kid: "k", Crv: "P-256", X: "AQ", Y: "AQ"},
+		"bad rsa encoding":  {Kty: "RSA", Kid: "k", N: "!!", E: "AQAB"},
+		"missing modulus":   {Kty: "RSA", Kid: "k", E: "AQAB"},
+		"zero exponent":     {Kty: "RSA", Kid: "k", N: "AQAB", E: ""},
+		"exponent one":      {Kty: "RSA", Kid: "k", N: "AQAB", E: "AQ"},
+		"exponent overflow": {Kty: "RSA", Kid: "k", N: "AQAB", E: "AQAAAAAAAAAA"},
+	}
+	for name, jwk := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := jwk.publicKey(); err == nil {
+				t.Error("Expected error")
+			}
+			// A document with nothing usable fails as a whole
+			if _, err := parseJWKS(context.Background(), jwksDocument(t, jwk)); err == nil {
+				t.Error("Expected error parsing a document without usable keys")
+			}
+		})
+	}
+}
+
+func TestParseJWKS_SkipsUnusableKeys(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	doc := jwksDocument(t,
+		jsonWebKey{Kty: "OKP", Kid: "ed", Crv: "Ed25519", X: "AA"},
+		rsaJWK("rsa-1", &key.PublicKey),
+		jsonWebKey{Kty: "EC", Kid: "p192", Crv: "P-192", X: "AA", Y: "AA"},
+	)
+
+	keys, err := parseJWKS(context.Background(), doc)
+	if err != nil {
+		t.Fatalf("Expected the usable key to be kept, got %v", err)
+	}
+	if len(keys) != 1 || keys["rsa-1"] == nil {
+		t.Errorf("Expected only rsa-1, got %v", keys)
+	}
+}
+
+func TestJWKS_ConcurrentRefreshFetchesOnce(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		w.Write(jwksDocument(t, rsaJWK("old", &oldKey.PublicKey), rsaJWK("new", &newKey.PublicKey)))
+	}))
+	defer server.Close()
+
+	jwks := NewJWKS(server.URL, JWKSOptions{MinRefreshInterval: time.Minute})
+	ctx := context.Background()
+	if _, err := jwks.Key(ctx, "old"); err != nil {
+		t.Fatalf("Expected old key, got %v", err)
+	}
+	jwks.lastAttempt = time.Time{}
+
+	// Lookups of an unknown kid share one fetch
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := jwks.Key(ctx, "missing")
+			errs <- err
+		}()
+	}
+
+	// While the fetch is blocked, cached keys are still served and callers can give up
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := jwks.Key(ctx, "new"); err != nil {
+		t.Errorf("Expected cached key during refresh, got %v", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := jwks.Key(cancelled, "missing"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled caller not to wait for the fetch, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey after refresh, got %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("Expected 2 fetches, got %d", got)
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+	"github.com/wolfman30/api-gateway-go/internal/logging"
+)
+
+var (
+	// ErrNoCredentials means the request carried no credentials this authenticator understands.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means credentials were presented but could not be verified.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator resolves the principal behind a request.
+// It returns ErrNoCredentials when the request does not use its scheme,
+// so several authenticators can be tried in turn.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries each authenticator in order and uses the first one that
+// recognises the request's credentials.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator.
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// Require wraps next so it only runs for callers that authenticate and hold scope.
+// The principal is available to next through PrincipalFromContext.
+func Require(a Authenticator, scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		// Signed requests are buffered for verification, so oversized bodies fail here
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Write(w, r, apierror.New(apierror.CodePayloadTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit)).
+				WithDetails(map[string]int64{"maxBytes": tooLarge.Limit}))
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, ErrNoCredentials) {
+				logging.FromContext(r.Context()).Warn("Authentication failed", logging.KeyError, err)
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api-gateway", APIKey header="X-API-Key", `+HMACAlgorithm)
+			apierror.Write(w, r, apierror.New(apierror.CodeUnauthorized, "Authentication required"))
+			return
+		}
+
+		if scope != "" && !p.HasScope(scope) {
+			logging.FromContext(r.Context()).Warn("Principal lacks required scope", logging.KeyPrincipal, p.ID, "scope", scope)
+			if p.Method == "oauth_token" {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			}
+			apierror.Write(w, r, apierror.New(apierror.CodeInsufficientScope, "Missing required scope").
+				WithDetails(map[string]string{"requiredScope": scope}))
+			return
+		}
+
+		ctx := logging.With(WithPrincipal(r.Context(), p), logging.KeyPrincipal, p.ID, "authMethod", p.Method)
+		next(w, r.WithContext(ctx))
+	}
+}
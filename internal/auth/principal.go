@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// Scopes understood by the gateway.
+const (
+	ScopeReelsWrite = "reels:write"
+	ScopeRunsRead   = "runs:read"
+	ScopeKeysAdmin  = "keys:admin"
+	// ScopeAdmin bypasses project authorization.
+	ScopeAdmin = "admin"
+	// ScopeProjectPrefix grants access to one project, e.g. "project:proj_123".
+	ScopeProjectPrefix = "project:"
+)
+
+// RoleAdmin is the identity-provider role that bypasses project authorization.
+const RoleAdmin = "admin"
+
+// KnownScopes lists every fixed scope that can be granted to a credential.
+// Project scopes (ScopeProjectPrefix + id) are accepted in addition.
+var KnownScopes = []string{ScopeReelsWrite, ScopeRunsRead, ScopeKeysAdmin, ScopeAdmin}
+
+// Principal identifies the authenticated caller of a request.
+type Principal struct {
+	ID       string
+	Method   string // how the caller authenticated, e.g. "api_key"
+	Scopes   []string
+	Projects []string
+	Admin    bool
+}
+
+// HasScope reports whether the principal was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	return p != nil && slices.Contains(p.Scopes, scope)
+}
+
+// CanAccessProject reports whether the principal may act on projectID.
+func (p *Principal) CanAccessProject(projectID string) bool {
+	if p == nil {
+		return false
+	}
+	return p.Admin || (projectID != "" && slices.Contains(p.Projects, projectID))
+}
+
+// newScopedPrincipal builds a principal whose project access and admin role
+// are expressed as scopes, as for API keys and gateway-issued tokens.
+func newScopedPrincipal(id, method string, scopes []string) *Principal {
+	p := &Principal{ID: id, Method: method, Scopes: scopes}
+	for _, scope := range scopes {
+		if project, ok := strings.CutPrefix(scope, ScopeProjectPrefix); ok && project != "" {
+			p.Projects = append(p.Projects, project)
+		}
+	}
+	p.Admin = slices.Contains(scopes, ScopeAdmin)
+	return p
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by the auth middleware, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import "testing"
+
+func TestPrincipal_CanAccessProject(t *testing.T) {
+	scoped := newScopedPrincipal("apikey:1", "api_key", []string{ScopeReelsWrite, "project:proj_a", "project:"})
+	admin := newScopedPrincipal("apikey:2", "api_key", []string{ScopeReelsWrite, ScopeAdmin})
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		project   string
+		want      bool
+	}{
+		{name: "allowed project", principal: scoped, project: "proj_a", want: true},
+		{name: "other project", principal: scoped, project: "proj_b", want: false},
+		{name: "empty project", principal: scoped, project: "", want: false},
+		{name: "admin bypass", principal: admin, project: "proj_b", want: true},
+		{name: "nil principal", principal: nil, project: "proj_a", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.CanAccessProject(tt.project); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if len(scoped.Projects) != 1 {
+		t.Errorf("Expected empty project scope to be ignored, got %v", scoped.Projects)
+	}
+}
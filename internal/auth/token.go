@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// DefaultTokenTTL is the lifetime of gateway-issued access tokens.
+const DefaultTokenTTL = 15 * time.Minute
+
+// TokenClaims are the claims carried by gateway-issued access tokens.
+// Scope is a space-delimited list, as in RFC 8693.
+type TokenClaims struct {
+	Scope string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Scopes splits the scope claim into individual scopes.
+func (c *TokenClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// TokenIssuer signs short-lived HS256 access tokens with the gateway key.
+type TokenIssuer struct {
+	keys   atomic.Pointer[tokenKeys]
+	issuer string
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// tokenKeys are swapped as a unit when the signing key rotates.
+type tokenKeys struct {
+	current  []byte
+	previous [][]byte
+}
+
+// NewTokenIssuer creates an issuer that signs with key and stamps tokens with issuer.
+// A zero ttl uses DefaultTokenTTL.
+func NewTokenIssuer(key []byte, issuer string, ttl time.Duration) *TokenIssuer {
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	i := &TokenIssuer{issuer: issuer, ttl: ttl, now: time.Now}
+	i.SetKeys(key)
+	return i
+}
+
+// SetKeys replaces the signing key. Tokens signed with any of previous still
+// verify, so tokens issued before a rotation stay valid until they expire.
+func (i *TokenIssuer) SetKeys(current []byte, previous ...[]byte) {
+	keys := &tokenKeys{current: current}
+	for _, key := range previous {
+		if len(key) > 0 && !bytes.Equal(key, current) {
+			keys.previous = append(keys.previous, key)
+		}
+	}
+	i.keys.Store(keys)
+}
+
+// Issue returns a signed token for subject holding scopes, and its expiry.
+func (i *TokenIssuer) Issue(subject string, scopes []string) (string, time.Time, error) {
+	now := i.now()
+	expiresAt := now.Add(i.ttl)
+	claims := TokenClaims{
+		Scope: strings.Join(scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    i.issuer,
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.keys.Load().current)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("signing token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// Verify parses and validates a token issued by this issuer.
+func (i *TokenIssuer) Verify(token string) (*TokenClaims, error) {
+	keys := i.keys.Load()
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		if len(keys.previous) == 0 {
+			return keys.current, nil
+		}
+		set := jwt.VerificationKeySet{Keys: []jwt.VerificationKey{keys.current}}
+		for _, key := range keys.previous {
+			set.Keys = append(set.Keys, key)
+		}
+		return set, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(i.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(i.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return claims, nil
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// TokenAuthenticator authenticates bearer tokens issued by the gateway.
+type TokenAuthenticator struct {
+	issuer *TokenIssuer
+}
+
+// NewTokenAuthenticator creates an authenticator for tokens from issuer.
+func NewTokenAuthenticator(issuer *TokenIssuer) *TokenAuthenticator {
+	return &TokenAuthenticator{issuer: issuer}
+}
+
+// Authenticate implements Authenticator. Tokens from other issuers are left
+// for the next authenticator in the chain.
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := BearerToken(r)
+	if !ok || unverifiedIssuer(token) != a.issuer.issuer {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.issuer.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	return newScopedPrincipal("client:"+claims.Subject, "oauth_token", claims.Scopes()), nil
+}
+
+var (
+	// ErrInvalidClient is returned when client authentication fails (RFC 6749 invalid_client).
+	ErrInvalidClient = errors.New("invalid client")
+	// ErrInvalidScope is returned when a client requests scopes it was not granted (RFC 6749 invalid_scope).
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// OAuthClient is a machine client allowed to use the client-credentials grant.
+type OAuthClient struct {
+	ID     string
+	Secret string
+	Scopes []string
+}
+
+// ClientCredentials implements the OAuth2 client-credentials grant.
+type ClientCredentials struct {
+	clients atomic.Pointer[map[string]OAuthClient]
+	issuer  *TokenIssuer
+}
+
+// NewClientCredentials creates a grant for clients whose tokens are signed by issuer.
+// Clients with an empty ID or secret are ignored.
+func NewClientCredentials(issuer *TokenIssuer, clients ...OAuthClient) *ClientCredentials {
+	cc := &ClientCredentials{issuer: issuer}
+	cc.SetClients(clients...)
+	return cc
+}
+
+// SetClients replaces the registered clients, e.g. after their secrets rotate.
+func (cc *ClientCredentials) SetClients(clients ...OAuthClient) {
+	byID := make(map[string]OAuthClient, len(clients))
+	for _, c := range clients {
+		if c.ID != "" && c.Secret != "" {
+			byID[c.ID] = c
+		}
+	}
+	cc.clients.Store(&byID)
+}
+
+// Token authenticates the client and issues a token. An empty scope requests
+// every scope the client was granted.
+func (cc *ClientCredentials) Token(clientID, clientSecret, scope string) (token string, expiresAt time.Time, scopes []string, err error) {
+	client, ok := (*cc.clients.Load())[clientID]
+	if !ok || !constantTimeEqual(client.Secret, clientSecret) {
+		return "", time.Time{}, nil, ErrInvalidClient
+	}
+
+	scopes = strings.Fields(scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, s := range scopes {
+		if !slices.Contains(client.Scopes, s) {
+			return "", time.Time{}, nil, fmt.Errorf("%w: %s", ErrInvalidScope, s)
+		}
+	}
+
+	token, expiresAt, err = cc.issuer.Issue(client.ID, scopes)
+	return token, expiresAt, scopes, err
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTokenIssuer_IssueAndVerify(t *testing.T) {
+	issuer := NewTokenIssuer([]byte("test-secret"), "api-gateway-dev", time.Minute)
+
+	token, expiresAt, err := issuer.Issue("partner-a", []string{ScopeReelsWrite, ScopeRunsRead})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if time.Until(expiresAt) > time.Minute {
+		t.Errorf("Expected expiry within ttl, got %v", expiresAt)
+	}
+
+	claims, err := issuer.Verify(token)
+	if err != nil {
+		t.Fatalf("Expected token to verify, got %v", err)
+	}
+	if claims.Subject != "partner-a" || len(claims.Scopes()) != 2 {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	other := NewTokenIssuer([]byte("other-secret"), "api-gateway-dev", time.Minute)
+	if _, err := other.Verify(token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for wrong key, got %v", err)
+	}
+
+	issuer.now = func() time.Time { return expiresAt.Add(time.Second) }
+	if _, err := issuer.Verify(token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for expired token, got %v", err)
+	}
+}
+
+func TestTokenIssuer_KeyRotation(t *testing.T) {
+	issuer := NewTokenIssuer([]byte("old-secret"), "api-gateway-dev", time.Minute)
+	oldToken, _, _ := issuer.Issue("partner-a", []string{ScopeReelsWrite})
+
+	// During rotation the previous key still verifies, new tokens use the new key
+	issuer.SetKeys([]byte("new-secret"), []byte("old-secret"))
+	if _, err := issuer.Verify(oldToken); err != nil {
+		t.Errorf("Expected token signed with the previous key to verify, got %v", err)
+	}
+	newToken, _, _ := issuer.Issue("partner-a", []string{ScopeReelsWrite})
+	if _, err := NewTokenIssuer([]byte("new-secret"), "api-gateway-dev", time.Minute).Verify(newToken); err != nil {
+		t.Errorf("Expected new tokens to be signed with the new key, got %v", err)
+	}
+
+	// Once the previous key is dropped its tokens are rejected
+	issuer.SetKeys([]byte("new-secret"))
+	if _, err := issuer.Verify(oldToken); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials after the overlap ends, got %v", err)
+	}
+}
+
+func TestTokenIssuer_RejectsAlgNone(t *testing.T) {
+	issuer := NewTokenIssuer([]byte("test-secret"), "api-gateway-dev", time.Minute)
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, TokenClaims{
+		Scope: ScopeReelsWrite,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "api-gateway-dev",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	if _, err := issuer.Verify(unsigned); err == nil {
+		t.Error("Expected unsigned token to be rejected")
+	}
+}
+
+func TestClientCredentials_Token(t *testing.T) {
+	issuer := NewTokenIssuer([]byte("test-secret"), "api-gateway-dev", time.Minute)
+	cc := NewClientCredentials(issuer, OAuthClient{ID: "client-a", Secret: "s3cret", Scopes: []string{ScopeReelsWrite, ScopeRunsRead}})
+
+	if _, _, _, err := cc.Token("client-a", "wrong", ""); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("Expected ErrInvalidClient, got %v", err)
+	}
+	if _, _, _, err := cc.Token("client-a", "s3cret", ScopeKeysAdmin); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Expected ErrInvalidScope, got %v", err)
+	}
+
+	token, _, scopes, err := cc.Token("client-a", "s3cret", ScopeRunsRead)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(scopes) != 1 || scopes[0] != ScopeRunsRead {
+		t.Errorf("Expected only runs:read, got %v", scopes)
+	}
+
+	// The token authenticates and the middleware enforces its scopes
+	handler := Require(NewTokenAuthenticator(issuer), ScopeReelsWrite, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/reels", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for runs:read token on /reels, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestNewClientCredentials_IgnoresUnconfiguredClient(t *testing.T) {
+	cc := NewClientCredentials(NewTokenIssuer([]byte("k"), "iss", 0), OAuthClient{ID: "", Secret: ""})
+	if _, _, _, err := cc.Token("", "", ""); !errors.Is(err, ErrInvalidClient) {
+		t.Errorf("Expected ErrInvalidClient for empty credentials, got %v", err)
+	}
+}
@@ -3,22 +3,52 @@ package bus
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/wolfman30/api-gateway-go/internal/logging"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracerName identifies spans created by the publisher.
+const tracerName = "github.com/wolfman30/api-gateway-go/internal/bus"
+
 // SQSClient defines the interface for SQS operations (for testing).
 type SQSClient interface {
 	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
 }
 
+// ErrPublisherClosed is returned when publishing after Close.
+var ErrPublisherClosed = errors.New("publisher is closed")
+
+// PublishObserver is notified of every publish attempt (for metrics).
+// retries counts SendMessage attempts beyond the first made by the SDK.
+type PublishObserver interface {
+	ObservePublish(err error, duration time.Duration, retries int)
+}
+
 // Publisher handles publishing commands to SQS.
 type Publisher struct {
 	queueURL  string
 	sqsClient SQSClient
+	observer  PublishObserver
+
+	mu       sync.RWMutex
+	closed   bool
+	inflight sync.WaitGroup
 }
 
 // NewPublisher creates a new SQS command publisher.
@@ -29,31 +59,174 @@ func NewPublisher(queueURL string, sqsClient SQSClient) *Publisher {
 	}
 }
 
-// PublishReelCommand sends a reel command to SQS for orchestrator pickup.
-func (p *Publisher) PublishReelCommand(runID string, payload interface{}) error {
+// SetObserver registers an observer for publish outcomes.
+func (p *Publisher) SetObserver(o PublishObserver) {
+	p.observer = o
+}
+
+// QueueURL returns the SQS queue URL commands are published to.
+func (p *Publisher) QueueURL() string {
+	return p.queueURL
+}
+
+// NewReelCommandInput builds the SQS message for a reel command. The request
+// ID and trace context in ctx are forwarded as the requestId and
+// traceparent/tracestate attributes.
+func NewReelCommandInput(ctx context.Context, queueURL, runID string, payload interface{}) (*sqs.SendMessageInput, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Create the SQS message with the runID as a message attribute
-	input := &sqs.SendMessageInput{
-		QueueUrl:    aws.String(p.queueURL),
-		MessageBody: aws.String(string(body)),
-		MessageAttributes: map[string]types.MessageAttributeValue{
-			"runId": {
-				DataType:    aws.String("String"),
-				StringValue: aws.String(runID),
-			},
+	attrs := map[string]types.MessageAttributeValue{
+		"runId": {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(runID),
 		},
 	}
+	if id := requestid.FromContext(ctx); id != "" {
+		attrs["requestId"] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(id),
+		}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, messageAttributeCarrier(attrs))
+
+	return &sqs.SendMessageInput{
+		QueueUrl:          aws.String(queueURL),
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: attrs,
+	}, nil
+}
+
+// BuildReelCommand builds the message PublishReelCommand would send for
+// runID, without sending it. The trace attributes come from the same
+// producer span a publish starts, so a dry run shows the traceparent a real
+// message carries.
+func BuildReelCommand(ctx context.Context, queueURL, runID string, payload interface{}) (*sqs.SendMessageInput, error) {
+	ctx, span := startPublishSpan(ctx, queueURL)
+	defer span.End()
+	span.SetAttributes(attribute.Bool("messaging.dry_run", true))
+
+	input, err := NewReelCommandInput(ctx, queueURL, runID, payload)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return input, err
+}
+
+// startPublishSpan starts the producer span a reel command is sent under.
+func startPublishSpan(ctx context.Context, queueURL string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "publish reel-command",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemAWSSqs,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(queueURL),
+		),
+	)
+}
+
+// messageAttributeCarrier lets OpenTelemetry propagators read and write SQS
+// message attributes, so the orchestrator can continue the trace.
+type messageAttributeCarrier map[string]types.MessageAttributeValue
+
+func (c messageAttributeCarrier) Get(key string) string {
+	return aws.ToString(c[key].StringValue)
+}
+
+func (c messageAttributeCarrier) Set(key, value string) {
+	c[key] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func (c messageAttributeCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+var _ propagation.TextMapCarrier = messageAttributeCarrier(nil)
+
+// PublishReelCommand sends a reel command to SQS for orchestrator pickup.
+// The send is not cancelled with ctx, so a client disconnect cannot abandon
+// a half-published command; ctx only supplies the trace context.
+func (p *Publisher) PublishReelCommand(ctx context.Context, runID string, payload interface{}) error {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPublisherClosed
+	}
+	p.inflight.Add(1)
+	p.mu.RUnlock()
+	defer p.inflight.Done()
+
+	ctx, span := startPublishSpan(context.WithoutCancel(ctx), p.queueURL)
+	defer span.End()
+
+	input, err := NewReelCommandInput(ctx, p.queueURL, runID, payload)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
 
-	_, err = p.sqsClient.SendMessage(context.Background(), input)
+	logger := logging.FromContext(ctx).With(logging.KeyRunID, runID, "queueUrl", p.queueURL)
+	var attempts atomic.Int32
+	start := time.Now()
+	out, err := p.sqsClient.SendMessage(ctx, input, countAttempts(&attempts))
+	if p.observer != nil {
+		p.observer.ObservePublish(err, time.Since(start), max(int(attempts.Load())-1, 0))
+	}
 	if err != nil {
-		log.Printf("Failed to send message to SQS for runID=%s: %v", runID, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "SendMessage failed")
+		logger.Error("Failed to send reel command to SQS", "attempts", attempts.Load(), logging.KeyError, err)
 		return err
 	}
 
-	log.Printf("Successfully published reel command for runID=%s to queue=%s", runID, p.queueURL)
+	var messageID string
+	if out != nil && out.MessageId != nil {
+		messageID = *out.MessageId
+		span.SetAttributes(semconv.MessagingMessageID(messageID))
+	}
+	logger.Info("Published reel command", "messageId", messageID, "attempts", attempts.Load())
 	return nil
 }
+
+// countAttempts adds a finalize middleware, which runs once per attempt
+// inside the SDK's retry loop, so retries can be counted.
+func countAttempts(attempts *atomic.Int32) func(*sqs.Options) {
+	return func(o *sqs.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("CountAttempts",
+				func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+					attempts.Add(1)
+					return next.HandleFinalize(ctx, in)
+				}), middleware.After)
+		})
+	}
+}
+
+// Close stops accepting new commands and waits for in-flight sends to finish
+// or for ctx to expire.
+func (p *Publisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("Publisher closed", "queueUrl", p.queueURL)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
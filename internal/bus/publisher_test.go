@@ -2,9 +2,23 @@ package bus
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // MockSQSClient is a mock implementation of SQSClient for testing.
@@ -46,15 +60,215 @@ func TestPublishReelCommand(t *testing.T) {
 		"idea":      "Test reel idea",
 	}
 
-	err := pub.PublishReelCommand("run-456", payload)
+	err := pub.PublishReelCommand(context.Background(), "run-456", payload)
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
 
 	// Test with invalid payload that can't be marshaled
 	invalidPayload := make(chan int) // channels can't be marshaled to JSON
-	err = pub.PublishReelCommand("run-789", invalidPayload)
+	err = pub.PublishReelCommand(context.Background(), "run-789", invalidPayload)
 	if err == nil {
 		t.Error("Expected error when marshaling invalid payload")
 	}
 }
+
+func TestNewReelCommandInput(t *testing.T) {
+	input, err := NewReelCommandInput(context.Background(), "https://sqs.us-east-1.amazonaws.com/123456789012/test-queue", "run-123", map[string]string{"projectId": "proj_123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if *input.MessageBody != `{"projectId":"proj_123"}` {
+		t.Errorf("Unexpected message body %s", *input.MessageBody)
+	}
+	if attr := input.MessageAttributes["runId"]; attr.StringValue == nil || *attr.StringValue != "run-123" {
+		t.Errorf("Expected runId attribute run-123, got %+v", attr)
+	}
+
+	// PublishReelCommand must send exactly the message a dry run reports
+	var sent *sqs.SendMessageInput
+	pub := NewPublisher(*input.QueueUrl, &MockSQSClient{
+		SendMessageFunc: func(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+			sent = params
+			return &sqs.SendMessageOutput{}, nil
+		},
+	})
+	if err := pub.PublishReelCommand(context.Background(), "run-123", map[string]string{"projectId": "proj_123"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if *sent.MessageBody != *input.MessageBody || *sent.QueueUrl != *input.QueueUrl {
+		t.Errorf("Published message %+v differs from built message %+v", sent, input)
+	}
+}
+
+func TestNewReelCommandInput_ForwardsRequestID(t *testing.T) {
+	input, err := NewReelCommandInput(context.Background(), "queue", "run-123", map[string]string{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := input.MessageAttributes["requestId"]; ok {
+		t.Error("Expected no requestId attribute outside a request")
+	}
+
+	ctx := requestid.WithRequestID(context.Background(), "req-abc")
+	input, err = NewReelCommandInput(ctx, "queue", "run-123", map[string]string{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if attr := input.MessageAttributes["requestId"]; attr.StringValue == nil || *attr.StringValue != "req-abc" {
+		t.Errorf("Expected requestId attribute req-abc, got %+v", attr)
+	}
+}
+
+func TestPublisher_Close(t *testing.T) {
+	release := make(chan struct{})
+	sending := make(chan struct{})
+	pub := NewPublisher("https://sqs.us-east-1.amazonaws.com/123456789012/test-queue", &MockSQSClient{
+		SendMessageFunc: func(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+			close(sending)
+			<-release
+			return &sqs.SendMessageOutput{}, nil
+		},
+	})
+
+	published := make(chan error, 1)
+	go func() { published <- pub.PublishReelCommand(context.Background(), "run-1", map[string]string{}) }()
+	<-sending
+
+	// Close waits for the in-flight send
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pub.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Close to wait for in-flight send, got %v", err)
+	}
+
+	close(release)
+	if err := <-published; err != nil {
+		t.Errorf("Expected in-flight publish to succeed, got %v", err)
+	}
+	if err := pub.Close(context.Background()); err != nil {
+		t.Errorf("Expected Close to succeed, got %v", err)
+	}
+	if err := pub.PublishReelCommand(context.Background(), "run-2", map[string]string{}); !errors.Is(err, ErrPublisherClosed) {
+		t.Errorf("Expected ErrPublisherClosed, got %v", err)
+	}
+}
+
+type recordingObserver struct {
+	err     error
+	retries int
+	calls   int
+}
+
+func (o *recordingObserver) ObservePublish(err error, duration time.Duration, retries int) {
+	o.err, o.retries = err, retries
+	o.calls++
+}
+
+func TestPublishReelCommand_ObservesRetries(t *testing.T) {
+	// The real SDK client retries a 500 before succeeding
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"MessageId":"msg-1"}`))
+	}))
+	defer server.Close()
+
+	client := sqs.New(sqs.Options{
+		Region:                           "us-east-1",
+		BaseEndpoint:                     aws.String(server.URL),
+		Credentials:                      credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		DisableMessageChecksumValidation: true,
+		Retryer: retry.NewStandard(func(o *retry.StandardOptions) {
+			o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+		}),
+	})
+
+	observer := &recordingObserver{}
+	pub := NewPublisher(server.URL+"/123456789012/test-queue", client)
+	pub.SetObserver(observer)
+
+	if err := pub.PublishReelCommand(context.Background(), "run-1", map[string]string{"projectId": "proj_1"}); err != nil {
+		t.Fatalf("Expected publish to succeed after retry, got %v", err)
+	}
+	if observer.calls != 1 || observer.err != nil || observer.retries != 1 {
+		t.Errorf("Expected one successful observation with 1 retry, got %+v", observer)
+	}
+}
+
+func TestPublishReelCommand_PropagatesTraceContext(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	var sent *sqs.SendMessageInput
+	pub := NewPublisher("https://sqs.us-east-1.amazonaws.com/123456789012/test-queue", &MockSQSClient{
+		SendMessageFunc: func(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+			sent = params
+			return &sqs.SendMessageOutput{MessageId: aws.String("msg-1")}, nil
+		},
+	})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "POST /reels")
+	if err := pub.PublishReelCommand(ctx, "run-1", map[string]string{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected producer and parent spans, got %d", len(spans))
+	}
+	producer := spans[0]
+	if producer.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("Expected producer span to be a child of the request span")
+	}
+
+	// The orchestrator continues the trace from the producer span
+	carrier := messageAttributeCarrier(sent.MessageAttributes)
+	remote := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), carrier))
+	if remote.TraceID() != parent.SpanContext().TraceID() || remote.SpanID() != producer.SpanContext.SpanID() {
+		t.Errorf("Expected traceparent for producer span, got %q", carrier.Get("traceparent"))
+	}
+	if carrier.Get("runId") != "run-1" {
+		t.Errorf("Expected runId attribute to be kept, got %q", carrier.Get("runId"))
+	}
+}
+
+func TestBuildReelCommand_MatchesPublishedTraceContext(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "POST /reels")
+	input, err := BuildReelCommand(ctx, "queue", "run-1", map[string]string{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	parent.End()
+
+	// Like a publish, the message continues from a producer span under the request
+	spans := exporter.GetSpans()
+	if len(spans) != 2 || spans[0].SpanKind != trace.SpanKindProducer || spans[0].Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("Expected a producer span under the request span, got %+v", spans)
+	}
+	remote := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), messageAttributeCarrier(input.MessageAttributes)))
+	if remote.TraceID() != parent.SpanContext().TraceID() || remote.SpanID() != spans[0].SpanContext.SpanID() {
+		t.Errorf("Expected traceparent for the producer span, got %q", messageAttributeCarrier(input.MessageAttributes).Get("traceparent"))
+	}
+}
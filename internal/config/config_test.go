@@ -1,32 +1,69 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestGetCurrentEnvironment_Default(t *testing.T) {
-	// Ensure ENVIRONMENT is unset or invalid
-	os.Unsetenv("ENVIRONMENT")
-	env := GetCurrentEnvironment()
-	if env != Dev {
-		t.Errorf("Expected default environment Dev, got %s", env)
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("DEFAULT_ENVIRONMENT", "")
+	if _, err := GetCurrentEnvironment(); !errors.Is(err, ErrEnvironmentUnset) {
+		t.Errorf("Expected ErrEnvironmentUnset without an opt-in default, got %v", err)
+	}
+
+	t.Setenv("DEFAULT_ENVIRONMENT", "development")
+	if env, err := GetCurrentEnvironment(); err != nil || env != Dev {
+		t.Errorf("Expected opt-in default Dev, got %s, %v", env, err)
+	}
+
+	// The default never masks a typo
+	t.Setenv("ENVIRONMENT", "prd")
+	if _, err := GetCurrentEnvironment(); err == nil {
+		t.Error("Expected error for unknown ENVIRONMENT despite DEFAULT_ENVIRONMENT")
 	}
 }
 
-func TestGetCurrentEnvironment_StagingProd(t *testing.T) {
-	os.Setenv("ENVIRONMENT", "Staging")
-	if GetCurrentEnvironment() != Staging {
-		t.Errorf("Expected Staging, got %s", GetCurrentEnvironment())
+func TestParseEnvironment(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Environment
+		wantErr bool
+	}{
+		{name: "dev", want: Dev},
+		{name: "Development", want: Dev},
+		{name: "Staging", want: Staging},
+		{name: "stage", want: Staging},
+		{name: "PROD", want: Prod},
+		{name: " production ", want: Prod},
+		{name: "prd", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEnvironment(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseEnvironment(%q) = %q, %v", tt.name, got, err)
+		}
 	}
+}
 
-	os.Setenv("ENVIRONMENT", "PROD")
-	if GetCurrentEnvironment() != Prod {
-		t.Errorf("Expected Prod, got %s", GetCurrentEnvironment())
+// loadFromEnv reads settings from environment variables only, without
+// cross-field validation, failing the test on any unparsable value. An unset
+// ENVIRONMENT falls back to dev through DEFAULT_ENVIRONMENT.
+func loadFromEnv(t *testing.T) *EnvironmentConfig {
+	t.Helper()
+	t.Setenv("DEFAULT_ENVIRONMENT", "dev")
+	l := newLoader(nil, nil)
+	cfg := l.load()
+	if len(l.problems) > 0 {
+		t.Fatalf("Expected no problems loading from the environment, got %v", l.problems)
 	}
+	return cfg
 }
 
-func TestLoadEnvironmentConfig_Fallbacks(t *testing.T) {
+func TestLoadFromEnv_Fallbacks(t *testing.T) {
 	// Test environment-specific and fallback values
 	os.Setenv("ENVIRONMENT", "dev")
 	os.Setenv("SQS_QUEUE_URL_DEV", "url-dev")
@@ -34,7 +71,7 @@ func TestLoadEnvironmentConfig_Fallbacks(t *testing.T) {
 	os.Setenv("API_PORT", "9090")
 	os.Setenv("LOG_LEVEL", "debug")
 
-	cfg := LoadEnvironmentConfig()
+	cfg := loadFromEnv(t)
 	if cfg.SqsQueueURL != "url-dev" {
 		t.Errorf("Expected SqsQueueURL 'url-dev', got %s", cfg.SqsQueueURL)
 	}
@@ -49,26 +86,114 @@ func TestLoadEnvironmentConfig_Fallbacks(t *testing.T) {
 	}
 }
 
+func TestLoadFromEnv_OAuthTokenTTL(t *testing.T) {
+	os.Unsetenv("OAUTH_TOKEN_TTL")
+	if ttl := loadFromEnv(t).OAuthTokenTTL; ttl != 15*time.Minute {
+		t.Errorf("Expected default OAuthTokenTTL 15m, got %s", ttl)
+	}
+
+	os.Setenv("OAUTH_TOKEN_TTL", "5m")
+	defer os.Unsetenv("OAUTH_TOKEN_TTL")
+	if ttl := loadFromEnv(t).OAuthTokenTTL; ttl != 5*time.Minute {
+		t.Errorf("Expected OAuthTokenTTL 5m, got %s", ttl)
+	}
+}
+
+func TestLoadFromEnv_SecretsRefreshInterval(t *testing.T) {
+	t.Setenv("SECRETS_REFRESH_INTERVAL", "")
+	if interval := loadFromEnv(t).SecretsRefreshInterval; interval != 5*time.Minute {
+		t.Errorf("Expected default SecretsRefreshInterval 5m, got %s", interval)
+	}
+
+	t.Setenv("SECRETS_REFRESH_INTERVAL", "0")
+	if interval := loadFromEnv(t).SecretsRefreshInterval; interval != 0 {
+		t.Errorf("Expected refresh disabled, got %s", interval)
+	}
+}
+
+func TestLoadFromEnv_JWKS(t *testing.T) {
+	os.Setenv("ENVIRONMENT", "staging")
+	os.Setenv("JWKS_URL", "https://idp.example.com/.well-known/jwks.json")
+	os.Setenv("JWT_ISSUER", "https://idp.example.com/")
+	os.Setenv("JWT_ISSUER_STAGING", "https://staging.idp.example.com/")
+	defer func() {
+		for _, name := range []string{"ENVIRONMENT", "JWKS_URL", "JWT_ISSUER", "JWT_ISSUER_STAGING"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	cfg := loadFromEnv(t)
+	if cfg.JwksURL != "https://idp.example.com/.well-known/jwks.json" {
+		t.Errorf("Expected base JwksURL, got %s", cfg.JwksURL)
+	}
+	if cfg.JwtIssuer != "https://staging.idp.example.com/" {
+		t.Errorf("Expected staging JwtIssuer, got %s", cfg.JwtIssuer)
+	}
+}
+
 func TestGetSecretName(t *testing.T) {
-	os.Setenv("ENVIRONMENT", "dev")
-	if name := GetSecretName("api-key"); name != "api-key-dev" {
+	if name := GetSecretName("api-key", Dev); name != "api-key-dev" {
 		t.Errorf("Expected api-key-dev for dev, got %s", name)
 	}
 
-	os.Setenv("ENVIRONMENT", "prod")
-	if name := GetSecretName("api-key"); name != "api-key-prod" {
+	if name := GetSecretName("api-key", Prod); name != "api-key-prod" {
 		t.Errorf("Expected api-key-prod for prod, got %s", name)
 	}
 }
 
-func TestIsLocalDevelopment(t *testing.T) {
-	os.Unsetenv("USE_LOCAL_SECRETS")
-	if IsLocalDevelopment() {
-		t.Error("Expected false when USE_LOCAL_SECRETS is unset")
+func TestLoadFromEnv_RequestDecoding(t *testing.T) {
+	cfg := loadFromEnv(t)
+	if cfg.MaxRequestBodyBytes != 1<<20 || !cfg.StrictJSON {
+		t.Errorf("Expected 1MiB strict decoding by default, got %d strict=%v", cfg.MaxRequestBodyBytes, cfg.StrictJSON)
+	}
+
+	os.Setenv("MAX_REQUEST_BODY_BYTES", "4096")
+	os.Setenv("STRICT_JSON", "false")
+	defer os.Unsetenv("MAX_REQUEST_BODY_BYTES")
+	defer os.Unsetenv("STRICT_JSON")
+
+	cfg = loadFromEnv(t)
+	if cfg.MaxRequestBodyBytes != 4096 || cfg.StrictJSON {
+		t.Errorf("Expected 4096 lenient decoding, got %d strict=%v", cfg.MaxRequestBodyBytes, cfg.StrictJSON)
+	}
+}
+
+func TestLoadFromEnv_CORS(t *testing.T) {
+	os.Setenv("ENVIRONMENT", "prod")
+	os.Setenv("CORS_ALLOWED_ORIGINS", "http://localhost:3000")
+	os.Setenv("CORS_ALLOWED_ORIGINS_PROD", "https://app.example.com, https://admin.example.com")
+	defer func() {
+		for _, name := range []string{"ENVIRONMENT", "CORS_ALLOWED_ORIGINS", "CORS_ALLOWED_ORIGINS_PROD"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	cfg := loadFromEnv(t)
+	if len(cfg.CorsAllowedOrigins) != 2 || cfg.CorsAllowedOrigins[1] != "https://admin.example.com" {
+		t.Errorf("Expected prod origins, got %v", cfg.CorsAllowedOrigins)
+	}
+	if len(cfg.CorsAllowedMethods) != 3 || cfg.CorsAllowCredentials || cfg.CorsMaxAge != 10*time.Minute {
+		t.Errorf("Unexpected CORS defaults: %+v", cfg)
 	}
+}
+
+func TestLoadFromEnv_TLS(t *testing.T) {
+	cfg := loadFromEnv(t)
+	if cfg.TLSEnabled() || cfg.TLSMinVersion != "1.2" || cfg.TLSReloadInterval != time.Minute {
+		t.Errorf("Expected TLS disabled with defaults, got %+v", cfg)
+	}
+
+	os.Setenv("ENVIRONMENT", "staging")
+	os.Setenv("TLS_CERT_SECRET_ID_STAGING", "gateway/tls-staging")
+	os.Setenv("TLS_MIN_VERSION", "1.3")
+	defer func() {
+		for _, name := range []string{"ENVIRONMENT", "TLS_CERT_SECRET_ID_STAGING", "TLS_MIN_VERSION"} {
+			os.Unsetenv(name)
+		}
+	}()
 
-	os.Setenv("USE_LOCAL_SECRETS", "true")
-	if !IsLocalDevelopment() {
-		t.Error("Expected true when USE_LOCAL_SECRETS is 'true'")
+	cfg = loadFromEnv(t)
+	if !cfg.TLSEnabled() || cfg.TLSCertSecretID != "gateway/tls-staging" || cfg.TLSMinVersion != "1.3" {
+		t.Errorf("Expected staging TLS from Secrets Manager, got %+v", cfg)
 	}
 }
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 // Environment represents the deployment environment
@@ -24,87 +26,242 @@ type EnvironmentConfig struct {
 	S3Bucket    string
 	ApiPort     string
 	LogLevel    string
+
+	// DynamoDBTable is the table shared by every task for API keys, runs,
+	// HMAC nonces and quotas; without it that state is per process. RunTTL
+	// is how long accepted runs can be looked up
+	DynamoDBTable string
+	RunTTL        time.Duration
+
+	// UseLocalSecrets reads secrets from LOCAL_* variables (local development only)
+	UseLocalSecrets bool
+
+	// SecretsProvider selects where secrets are read from: aws-secretsmanager,
+	// aws-ssm, vault, file or env; the remaining fields configure each backend
+	SecretsProvider    string
+	SecretsDir         string
+	SecretsEnvPrefix   string
+	SSMParameterPrefix string
+	VaultAddr          string
+	VaultNamespace     string
+	VaultKVMount       string
+	VaultKVPath        string
+	// VaultToken is read only from the VAULT_TOKEN environment variable so it
+	// never appears in config files or process arguments
+	VaultToken string
+
+	// OAuthTokenTTL is the lifetime of access tokens issued by /oauth/token
+	OAuthTokenTTL time.Duration
+	// OAuthClientProjects are the projects the configured OAuth client may access
+	OAuthClientProjects []string
+
+	// HTTP server timeouts and graceful shutdown
+	ReadTimeout         time.Duration
+	WriteTimeout        time.Duration
+	IdleTimeout         time.Duration
+	ShutdownDrainPeriod time.Duration
+	ShutdownTimeout     time.Duration
+
+	// ReelQuota is how many reels each project may submit per ReelQuotaWindow
+	// (0 disables the quota)
+	ReelQuota       int64
+	ReelQuotaWindow time.Duration
+
+	// Request bodies larger than MaxRequestBodyBytes are rejected; StrictJSON
+	// also rejects fields the API does not define
+	MaxRequestBodyBytes int64
+	StrictJSON          bool
+
+	// CORS for browser clients; disabled when CorsAllowedOrigins is empty
+	CorsAllowedOrigins   []string
+	CorsAllowedMethods   []string
+	CorsAllowedHeaders   []string
+	CorsAllowCredentials bool
+	CorsMaxAge           time.Duration
+
+	// TLS is served when a certificate is configured, from files or a Secrets
+	// Manager secret, and reloaded every TLSReloadInterval. TLSClientCAFile
+	// enables mutual TLS; TLSClientAuth is "none", "optional" or "require" and
+	// TLSClientIdentities maps certificate subjects to scopes (JSON)
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSCertSecretID     string
+	TLSMinVersion       string
+	TLSReloadInterval   time.Duration
+	TLSClientCAFile     string
+	TLSClientAuth       string
+	TLSClientIdentities string
+
+	// HealthCacheTTL is how long /readyz reuses dependency check results
+	HealthCacheTTL time.Duration
+	// SecretsMaxAge fails the secrets readiness check when secrets are older (0 disables)
+	SecretsMaxAge time.Duration
+	// SecretsRefreshInterval is how often secrets are re-read to pick up
+	// rotations (0 disables)
+	SecretsRefreshInterval time.Duration
+
+	// Tracing: exporter is "otlp", "stdout" or "none"
+	ServiceName     string
+	TracesExporter  string
+	OtlpEndpointURL string
+
+	// JwksURL enables verification of externally issued tokens against the
+	// identity provider's key set (http(s) URL or file path)
+	JwksURL     string
+	JwtIssuer   string
+	JwtAudience string
 }
 
-// GetCurrentEnvironment returns the current deployment environment
-func GetCurrentEnvironment() Environment {
-	env := strings.ToLower(os.Getenv("ENVIRONMENT"))
-	switch Environment(env) {
-	case Dev, Staging, Prod:
-		return Environment(env)
-	default:
-		return Dev // Default to dev
+// environmentAliases maps accepted spellings to environments
+var environmentAliases = map[string]Environment{
+	"dev":         Dev,
+	"development": Dev,
+	"staging":     Staging,
+	"stage":       Staging,
+	"prod":        Prod,
+	"production":  Prod,
+}
+
+// ErrEnvironmentUnset is returned when neither ENVIRONMENT nor DEFAULT_ENVIRONMENT is set
+var ErrEnvironmentUnset = errors.New("ENVIRONMENT is not set (set DEFAULT_ENVIRONMENT to opt into a default)")
+
+// ParseEnvironment resolves an environment name or alias, case-insensitively.
+// Unknown names are an error rather than a silent fallback, so a typo cannot
+// point production at dev resources.
+func ParseEnvironment(name string) (Environment, error) {
+	if env, ok := environmentAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return env, nil
 	}
+	return "", fmt.Errorf("unknown environment %q (use dev, staging or prod)", name)
 }
 
-// LoadEnvironmentConfig loads environment-specific configuration
-func LoadEnvironmentConfig() *EnvironmentConfig {
-	currentEnv := GetCurrentEnvironment()
+// GetCurrentEnvironment resolves ENVIRONMENT, or DEFAULT_ENVIRONMENT when
+// ENVIRONMENT is unset. There is no implicit default.
+func GetCurrentEnvironment() (Environment, error) {
+	return resolveEnvironment(os.Getenv("ENVIRONMENT"), os.Getenv("DEFAULT_ENVIRONMENT"))
+}
+
+// resolveEnvironment parses name, falling back to def only when name is empty
+func resolveEnvironment(name, def string) (Environment, error) {
+	if strings.TrimSpace(name) != "" {
+		env, err := ParseEnvironment(name)
+		if err != nil {
+			return "", fmt.Errorf("ENVIRONMENT: %w", err)
+		}
+		return env, nil
+	}
+	if strings.TrimSpace(def) == "" {
+		return "", ErrEnvironmentUnset
+	}
+	env, err := ParseEnvironment(def)
+	if err != nil {
+		return "", fmt.Errorf("DEFAULT_ENVIRONMENT: %w", err)
+	}
+	return env, nil
+}
+
+// load reads every setting from the loader's layers.
+func (l *loader) load() *EnvironmentConfig {
+	currentEnv := l.environment()
+	l.env = currentEnv
 
 	config := &EnvironmentConfig{
 		Environment: currentEnv,
 	}
 
-	// Load environment-specific values with suffixes
-	suffix := string(currentEnv)
+	// Load environment-specific values with suffixes, falling back to the base name
+	config.EcsCluster = l.stringForEnvironment("ECS_CLUSTER", "")
+	config.SqsQueueURL = l.stringForEnvironment("SQS_QUEUE_URL", "")
+	config.S3Bucket = l.stringForEnvironment("S3_BUCKET", "")
+	config.DynamoDBTable = l.stringForEnvironment("DYNAMODB_TABLE", "")
+	config.RunTTL = l.duration("RUN_TTL", 7*24*time.Hour)
+	config.ClusterName = l.stringForEnvironment("CLUSTER_NAME", "")
 
-	// ECS Cluster (environment-specific)
-	config.EcsCluster = os.Getenv("ECS_CLUSTER_" + strings.ToUpper(suffix))
-	if config.EcsCluster == "" {
-		config.EcsCluster = os.Getenv("ECS_CLUSTER") // Fallback to base name
-	}
+	// External identity provider (environment-specific)
+	config.JwksURL = l.stringForEnvironment("JWKS_URL", "")
+	config.JwtIssuer = l.stringForEnvironment("JWT_ISSUER", "")
+	config.JwtAudience = l.stringForEnvironment("JWT_AUDIENCE", "")
 
-	// SQS Queue URL (environment-specific)
-	config.SqsQueueURL = os.Getenv("SQS_QUEUE_URL_" + strings.ToUpper(suffix))
-	if config.SqsQueueURL == "" {
-		config.SqsQueueURL = os.Getenv("SQS_QUEUE_URL") // Fallback to base name
-	}
+	config.ApiPort = l.string("API_PORT", "8080")
+	config.LogLevel = l.string("LOG_LEVEL", "info")
+	config.UseLocalSecrets = l.bool("USE_LOCAL_SECRETS", false)
 
-	// S3 Bucket (environment-specific)
-	config.S3Bucket = os.Getenv("S3_BUCKET_" + strings.ToUpper(suffix))
-	if config.S3Bucket == "" {
-		config.S3Bucket = os.Getenv("S3_BUCKET") // Fallback to base name
+	// Secrets backend (USE_LOCAL_SECRETS=true keeps selecting env variables)
+	defaultProvider := SecretsProviderSecretsManager
+	if config.UseLocalSecrets {
+		defaultProvider = SecretsProviderEnv
 	}
+	config.SecretsProvider = l.string("SECRETS_PROVIDER", defaultProvider)
+	config.SecretsDir = l.string("SECRETS_DIR", "/run/secrets")
+	config.SecretsEnvPrefix = l.string("SECRETS_ENV_PREFIX", "LOCAL_")
+	config.SSMParameterPrefix = l.stringForEnvironment("SSM_PARAMETER_PREFIX", "/api-gateway/")
+	config.VaultAddr = l.stringForEnvironment("VAULT_ADDR", "")
+	config.VaultNamespace = l.string("VAULT_NAMESPACE", "")
+	config.VaultKVMount = l.string("VAULT_KV_MOUNT", "secret")
+	config.VaultKVPath = l.stringForEnvironment("VAULT_KV_PATH", "api-gateway")
+	config.VaultToken = os.Getenv("VAULT_TOKEN")
 
-	// Cluster Name (environment-specific)
-	config.ClusterName = os.Getenv("CLUSTER_NAME_" + strings.ToUpper(suffix))
-	if config.ClusterName == "" {
-		config.ClusterName = os.Getenv("CLUSTER_NAME") // Fallback to base name
-	}
+	// OAuth access token lifetime
+	config.OAuthTokenTTL = l.duration("OAUTH_TOKEN_TTL", 15*time.Minute)
+	config.OAuthClientProjects = l.list("OAUTH_CLIENT_PROJECTS", nil)
 
-	// API Port
-	config.ApiPort = os.Getenv("API_PORT")
-	if config.ApiPort == "" {
-		config.ApiPort = "8080"
-	}
+	// HTTP server timeouts and graceful shutdown
+	config.ReadTimeout = l.duration("HTTP_READ_TIMEOUT", 15*time.Second)
+	config.WriteTimeout = l.duration("HTTP_WRITE_TIMEOUT", 30*time.Second)
+	config.IdleTimeout = l.duration("HTTP_IDLE_TIMEOUT", 120*time.Second)
+	config.ShutdownDrainPeriod = l.duration("SHUTDOWN_DRAIN_PERIOD", 5*time.Second)
+	config.ShutdownTimeout = l.duration("SHUTDOWN_TIMEOUT", 20*time.Second)
 
-	// Log Level
-	config.LogLevel = os.Getenv("LOG_LEVEL")
-	if config.LogLevel == "" {
-		config.LogLevel = "info"
-	}
+	// Request decoding
+	config.ReelQuota = l.count("REEL_QUOTA", 0)
+	config.ReelQuotaWindow = l.duration("REEL_QUOTA_WINDOW", 24*time.Hour)
+	config.MaxRequestBodyBytes = l.int64("MAX_REQUEST_BODY_BYTES", 1<<20)
+	config.StrictJSON = l.bool("STRICT_JSON", true)
+
+	// CORS (origins usually differ per environment)
+	config.CorsAllowedOrigins = l.list("CORS_ALLOWED_ORIGINS", nil)
+	config.CorsAllowedMethods = l.list("CORS_ALLOWED_METHODS", []string{"GET", "POST", "DELETE"})
+	config.CorsAllowedHeaders = l.list("CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "Prefer"})
+	config.CorsAllowCredentials = l.bool("CORS_ALLOW_CREDENTIALS", false)
+	config.CorsMaxAge = l.duration("CORS_MAX_AGE", 10*time.Minute)
+
+	// TLS and mutual TLS (certificates differ per environment)
+	config.TLSCertFile = l.stringForEnvironment("TLS_CERT_FILE", "")
+	config.TLSKeyFile = l.stringForEnvironment("TLS_KEY_FILE", "")
+	config.TLSCertSecretID = l.stringForEnvironment("TLS_CERT_SECRET_ID", "")
+	config.TLSMinVersion = l.string("TLS_MIN_VERSION", "1.2")
+	config.TLSReloadInterval = l.duration("TLS_RELOAD_INTERVAL", time.Minute)
+	config.TLSClientCAFile = l.stringForEnvironment("TLS_CLIENT_CA_FILE", "")
+	config.TLSClientAuth = l.string("TLS_CLIENT_AUTH", "optional")
+	config.TLSClientIdentities = l.stringForEnvironment("TLS_CLIENT_IDENTITIES", "")
+
+	// OpenTelemetry tracing (standard OTEL_* variable names)
+	config.ServiceName = l.string("OTEL_SERVICE_NAME", "api-gateway")
+	config.TracesExporter = l.string("OTEL_TRACES_EXPORTER", "none")
+	config.OtlpEndpointURL = l.stringForEnvironment("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+
+	// Readiness checks
+	config.HealthCacheTTL = l.duration("HEALTH_CACHE_TTL", 5*time.Second)
+	config.SecretsMaxAge = l.duration("SECRETS_MAX_AGE", 0)
+
+	// Secrets rotation
+	config.SecretsRefreshInterval = l.duration("SECRETS_REFRESH_INTERVAL", 5*time.Minute)
 
 	return config
 }
 
-// GetSecretName returns the environment-specific secret name with fallback
+// TLSEnabled reports whether a server certificate is configured.
+func (c *EnvironmentConfig) TLSEnabled() bool {
+	return c.TLSCertSecretID != "" || (c.TLSCertFile != "" && c.TLSKeyFile != "")
+}
+
+// GetSecretName returns the environment-specific secret name
 // e.g., for secret "api-key" and env "dev", returns "api-key-dev"
-// If the env-specific secret is not found, returns the base name
-func GetSecretName(baseName string) string {
-	currentEnv := GetCurrentEnvironment()
-	if currentEnv == Dev {
-		return fmt.Sprintf("%s-dev", baseName)
-	}
-	return fmt.Sprintf("%s-%s", baseName, string(currentEnv))
+func GetSecretName(baseName string, env Environment) string {
+	return fmt.Sprintf("%s-%s", baseName, string(env))
 }
 
 // String returns the environment name as a string
 func (e Environment) String() string {
 	return string(e)
 }
-
-// IsLocalDevelopment checks if we should use local development secrets
-// This is only for local testing and should never be true in CI/CD or deployed environments
-func IsLocalDevelopment() bool {
-	return os.Getenv("USE_LOCAL_SECRETS") == "true"
-}
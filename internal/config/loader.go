@@ -0,0 +1,484 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are named by their environment variable. The same setting is
+// SQS_QUEUE_URL in the environment, sqsQueueUrl in a config file and
+// -sqs-queue-url on the command line. Values are resolved with precedence
+//
+//	flag > NAME_<ENV> > NAME > file "environments.<env>" section > file top level > default
+//
+// so a file can hold shared defaults while deployments override single
+// values with environment variables or flags.
+var settings = []struct {
+	name  string
+	usage string
+}{
+	{"ENVIRONMENT", "deployment environment: dev, staging or prod (aliases development, stage, production)"},
+	{"DEFAULT_ENVIRONMENT", "environment to assume when ENVIRONMENT is unset; without it ENVIRONMENT is required"},
+	{"API_PORT", "port to listen on"},
+	{"LOG_LEVEL", "debug, info, warn or error"},
+	{"USE_LOCAL_SECRETS", "read secrets from LOCAL_* variables (local development only)"},
+	{"SECRETS_PROVIDER", "secrets backend: aws-secretsmanager, aws-ssm, vault, file or env"},
+	{"SECRETS_DIR", "directory of secret files for the file provider"},
+	{"SECRETS_ENV_PREFIX", "variable prefix for the env provider"},
+	{"SSM_PARAMETER_PREFIX", "parameter name prefix for the aws-ssm provider"},
+	{"VAULT_ADDR", "Vault address for the vault provider"},
+	{"VAULT_NAMESPACE", "Vault Enterprise namespace"},
+	{"VAULT_KV_MOUNT", "Vault KV v2 mount"},
+	{"VAULT_KV_PATH", "path under the KV mount holding the gateway's secrets"},
+	{"ECS_CLUSTER", "ECS cluster name"},
+	{"CLUSTER_NAME", "cluster name"},
+	{"SQS_QUEUE_URL", "SQS queue URL for reel commands"},
+	{"S3_BUCKET", "S3 bucket for artifacts"},
+	{"DYNAMODB_TABLE", "DynamoDB table shared by every task for keys, runs, nonces and quotas"},
+	{"RUN_TTL", "how long accepted runs can be looked up"},
+	{"JWKS_URL", "external identity provider key set URL"},
+	{"JWT_ISSUER", "required issuer of external tokens"},
+	{"JWT_AUDIENCE", "required audience of external tokens"},
+	{"OAUTH_TOKEN_TTL", "lifetime of issued access tokens"},
+	{"OAUTH_CLIENT_PROJECTS", "comma-separated projects of the OAuth client"},
+	{"HTTP_READ_TIMEOUT", "server read timeout"},
+	{"HTTP_WRITE_TIMEOUT", "server write timeout"},
+	{"HTTP_IDLE_TIMEOUT", "server idle timeout"},
+	{"SHUTDOWN_DRAIN_PERIOD", "how long readiness fails before the listener closes"},
+	{"SHUTDOWN_TIMEOUT", "how long in-flight requests get to finish"},
+	{"REEL_QUOTA", "reels each project may submit per window (0 or unset disables)"},
+	{"REEL_QUOTA_WINDOW", "length of the reel quota window"},
+	{"MAX_REQUEST_BODY_BYTES", "largest accepted JSON body"},
+	{"STRICT_JSON", "reject unknown JSON fields"},
+	{"CORS_ALLOWED_ORIGINS", "comma-separated browser origins"},
+	{"CORS_ALLOWED_METHODS", "comma-separated CORS methods"},
+	{"CORS_ALLOWED_HEADERS", "comma-separated CORS request headers"},
+	{"CORS_ALLOW_CREDENTIALS", "allow credentialed CORS requests"},
+	{"CORS_MAX_AGE", "how long browsers cache preflights"},
+	{"TLS_CERT_FILE", "PEM certificate chain to serve"},
+	{"TLS_KEY_FILE", "PEM private key to serve"},
+	{"TLS_CERT_SECRET_ID", "Secrets Manager secret holding the certificate"},
+	{"TLS_MIN_VERSION", "minimum TLS version: 1.2 or 1.3"},
+	{"TLS_RELOAD_INTERVAL", "how often the certificate is checked for rotation"},
+	{"TLS_CLIENT_CA_FILE", "PEM CA bundle enabling mutual TLS"},
+	{"TLS_CLIENT_AUTH", "client certificates: none, optional or require"},
+	{"TLS_CLIENT_IDENTITIES", "JSON mapping client certificate subjects to scopes"},
+	{"OTEL_SERVICE_NAME", "service name on exported spans"},
+	{"OTEL_TRACES_EXPORTER", "otlp, stdout or none"},
+	{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTLP/HTTP collector URL"},
+	{"HEALTH_CACHE_TTL", "how long readiness check results are reused"},
+	{"SECRETS_MAX_AGE", "maximum secret age before readiness fails"},
+	{"SECRETS_REFRESH_INTERVAL", "how often to re-read secrets to pick up rotations (0 disables)"},
+}
+
+// fileSectionEnvironments holds per-environment overrides in a config file.
+const fileSectionEnvironments = "environments"
+
+// Load builds the configuration from a config file, the environment and
+// command-line args, then validates it. The file is named by -config or
+// CONFIG_FILE and may be YAML or JSON. All problems are reported together
+// in a *ValidationError. flag.ErrHelp is returned for -h.
+func Load(args []string) (*EnvironmentConfig, error) {
+	fs := flag.NewFlagSet("api-gateway", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or JSON config file")
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		values[s.name] = fs.String(flagName(s.name), "", s.usage+" ("+s.name+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		for name, v := range values {
+			if flagName(name) == f.Name {
+				flags[name] = *v
+			}
+		}
+	})
+
+	var file *fileValues
+	var problems []string
+	if *configFile != "" {
+		var err error
+		if file, err = readConfigFile(*configFile); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	l := newLoader(flags, file)
+	cfg := l.load()
+	problems = append(problems, l.problems...)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// ValidationError lists every configuration problem found.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// fileValues are a config file's settings keyed by setting name, with
+// per-environment sections kept apart.
+type fileValues struct {
+	values       map[string]string
+	environments map[Environment]map[string]string
+}
+
+// readConfigFile reads a YAML (.yaml, .yml) or JSON file. Unknown keys are
+// errors so typos do not silently fall back to defaults; the valid keys are
+// still returned so the rest of the configuration can be checked.
+func readConfigFile(path string) (*fileValues, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err = dec.Decode(&doc); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q (use .yaml, .yml or .json)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	file := &fileValues{environments: make(map[Environment]map[string]string)}
+	var problems []string
+	sections, _ := doc[fileSectionEnvironments].(map[string]any)
+	if raw, ok := doc[fileSectionEnvironments]; ok && sections == nil {
+		problems = append(problems, fmt.Sprintf("%s must be a map of environment names, got %T", fileSectionEnvironments, raw))
+	}
+	delete(doc, fileSectionEnvironments)
+
+	file.values, problems = fileSection(doc, "", problems)
+	for env, raw := range sections {
+		section, ok := raw.(map[string]any)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s.%s must be a map", fileSectionEnvironments, env))
+			continue
+		}
+		var values map[string]string
+		values, problems = fileSection(section, fileSectionEnvironments+"."+env+".", problems)
+		e, err := ParseEnvironment(env)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s.%s: %v", fileSectionEnvironments, env, err))
+			continue
+		}
+		file.environments[e] = values
+	}
+	if len(problems) > 0 {
+		return file, fmt.Errorf("config file %s: %s", path, strings.Join(problems, "; "))
+	}
+	return file, nil
+}
+
+// fileSection maps a file section's keys to setting names and renders values as strings.
+func fileSection(section map[string]any, prefix string, problems []string) (map[string]string, []string) {
+	values := make(map[string]string, len(section))
+	for key, raw := range section {
+		name, ok := settingForFileKey(key)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown setting %q", prefix+key))
+			continue
+		}
+		value, err := fileValue(raw)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s%s: %v", prefix, key, err))
+			continue
+		}
+		values[name] = value
+	}
+	return values, problems
+}
+
+// fileValue renders a scalar, or a list as a comma-separated string.
+func fileValue(raw any) (string, error) {
+	switch v := raw.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, float64, json.Number:
+		return fmt.Sprint(v), nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", raw)
+	}
+}
+
+func settingForFileKey(key string) (string, bool) {
+	for _, s := range settings {
+		if fileKey(s.name) == key {
+			return s.name, true
+		}
+	}
+	return "", false
+}
+
+// fileKey converts SQS_QUEUE_URL to sqsQueueUrl.
+func fileKey(name string) string {
+	parts := strings.Split(strings.ToLower(name), "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// flagName converts SQS_QUEUE_URL to sqs-queue-url.
+func flagName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// loader resolves settings across flags, environment variables and a config
+// file, collecting a problem for every value that does not parse.
+type loader struct {
+	flags    map[string]string
+	file     *fileValues
+	env      Environment
+	problems []string
+}
+
+func newLoader(flags map[string]string, file *fileValues) *loader {
+	return &loader{flags: flags, file: file, env: Dev}
+}
+
+// environment resolves ENVIRONMENT, which selects the per-environment layers.
+// When it cannot be resolved a problem is recorded and dev is assumed so the
+// remaining settings are still checked.
+func (l *loader) environment() Environment {
+	env, err := resolveEnvironment(l.string("ENVIRONMENT", ""), l.string("DEFAULT_ENVIRONMENT", ""))
+	if err != nil {
+		l.problems = append(l.problems, err.Error())
+		return Dev
+	}
+	return env
+}
+
+// lookup returns the raw value of name and where it came from.
+func (l *loader) lookup(name string, perEnvironment bool) (string, string) {
+	if v, ok := l.flags[name]; ok {
+		return v, "flag -" + flagName(name)
+	}
+	if perEnvironment {
+		envName := name + "_" + strings.ToUpper(string(l.env))
+		if v := os.Getenv(envName); v != "" {
+			return v, envName
+		}
+	}
+	if v := os.Getenv(name); v != "" {
+		return v, name
+	}
+	if l.file != nil {
+		if v, ok := l.file.environments[l.env][name]; ok && v != "" {
+			return v, "config file " + fileSectionEnvironments + "." + string(l.env) + "." + fileKey(name)
+		}
+		if v, ok := l.file.values[name]; ok && v != "" {
+			return v, "config file " + fileKey(name)
+		}
+	}
+	return "", ""
+}
+
+// string returns name, or def when unset.
+func (l *loader) string(name, def string) string {
+	if v, _ := l.lookup(name, false); v != "" {
+		return v
+	}
+	return def
+}
+
+// stringForEnvironment is string, also consulting NAME_<ENV>.
+func (l *loader) stringForEnvironment(name, def string) string {
+	if v, _ := l.lookup(name, true); v != "" {
+		return v
+	}
+	return def
+}
+
+// duration parses a non-negative Go duration.
+func (l *loader) duration(name string, def time.Duration) time.Duration {
+	v, from := l.lookup(name, false)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		l.problems = append(l.problems, fmt.Sprintf("%s: %q is not a non-negative duration such as 30s or 5m", from, v))
+		return def
+	}
+	return d
+}
+
+// int64 parses a positive integer.
+func (l *loader) int64(name string, def int64) int64 {
+	v, from := l.lookup(name, false)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		l.problems = append(l.problems, fmt.Sprintf("%s: %q is not a positive integer", from, v))
+		return def
+	}
+	return n
+}
+
+// count parses a non-negative integer, where 0 switches a limit off.
+func (l *loader) count(name string, def int64) int64 {
+	v, from := l.lookup(name, false)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		l.problems = append(l.problems, fmt.Sprintf("%s: %q is not a non-negative integer", from, v))
+		return def
+	}
+	return n
+}
+
+// bool parses a boolean.
+func (l *loader) bool(name string, def bool) bool {
+	v, from := l.lookup(name, false)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.problems = append(l.problems, fmt.Sprintf("%s: %q is not a boolean", from, v))
+		return def
+	}
+	return b
+}
+
+// list splits a comma-separated per-environment value.
+func (l *loader) list(name string, def []string) []string {
+	raw, _ := l.lookup(name, true)
+	if strings.TrimSpace(raw) == "" {
+		return def
+	}
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// validate reports every inconsistent or missing setting for the environment.
+func (c *EnvironmentConfig) validate() []string {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.ApiPort); err != nil || port < 1 || port > 65535 {
+		add("API_PORT: %q is not a valid port", c.ApiPort)
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, strings.ToLower(c.LogLevel)) {
+		add("LOG_LEVEL: %q must be debug, info, warn or error", c.LogLevel)
+	}
+	if !slices.Contains([]string{"otlp", "stdout", "none"}, c.TracesExporter) {
+		add("OTEL_TRACES_EXPORTER: %q must be otlp, stdout or none", c.TracesExporter)
+	}
+	if c.ReelQuota > 0 && c.ReelQuotaWindow <= 0 {
+		add("REEL_QUOTA_WINDOW must be positive when REEL_QUOTA is set")
+	}
+	if c.JwksURL != "" && c.JwtIssuer == "" {
+		add("JWT_ISSUER is required when JWKS_URL is set")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		add("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLSCertSecretID != "" && c.TLSCertFile != "" {
+		add("TLS_CERT_SECRET_ID and TLS_CERT_FILE are mutually exclusive")
+	}
+	if c.TLSMinVersion != "1.2" && c.TLSMinVersion != "1.3" {
+		add("TLS_MIN_VERSION: %q must be 1.2 or 1.3", c.TLSMinVersion)
+	}
+	if !slices.Contains([]string{"none", "optional", "require"}, strings.ToLower(c.TLSClientAuth)) {
+		add("TLS_CLIENT_AUTH: %q must be none, optional or require", c.TLSClientAuth)
+	}
+	if c.TLSClientCAFile != "" && !c.TLSEnabled() {
+		add("TLS_CLIENT_CA_FILE requires a server certificate (TLS_CERT_FILE/TLS_KEY_FILE or TLS_CERT_SECRET_ID)")
+	}
+	if slices.Contains(c.CorsAllowedOrigins, "*") && c.CorsAllowCredentials {
+		add("CORS_ALLOW_CREDENTIALS cannot be combined with CORS_ALLOWED_ORIGINS=*")
+	}
+
+	switch c.SecretsProvider {
+	case SecretsProviderSecretsManager, SecretsProviderSSM, SecretsProviderFile, SecretsProviderEnv:
+	case SecretsProviderVault:
+		if c.VaultAddr == "" {
+			add("VAULT_ADDR is required when SECRETS_PROVIDER=vault")
+		}
+		if c.VaultToken == "" {
+			add("VAULT_TOKEN is required when SECRETS_PROVIDER=vault")
+		}
+	default:
+		add("SECRETS_PROVIDER: %q must be aws-secretsmanager, aws-ssm, vault, file or env", c.SecretsProvider)
+	}
+
+	// Deployed environments must be wired to real infrastructure
+	if c.Environment != Dev {
+		if c.SqsQueueURL == "" {
+			add("SQS_QUEUE_URL is required in %s", c.Environment)
+		}
+		if c.DynamoDBTable == "" {
+			add("DYNAMODB_TABLE is required in %s", c.Environment)
+		}
+		if c.UseLocalSecrets {
+			add("USE_LOCAL_SECRETS must not be enabled in %s", c.Environment)
+		} else if c.SecretsProvider == SecretsProviderEnv {
+			add("SECRETS_PROVIDER=env must not be used in %s", c.Environment)
+		}
+		if c.TracesExporter == "otlp" && c.OtlpEndpointURL == "" {
+			add("OTEL_EXPORTER_OTLP_ENDPOINT is required in %s when OTEL_TRACES_EXPORTER=otlp", c.Environment)
+		}
+	}
+	return problems
+}
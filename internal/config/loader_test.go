@@ -0,0 +1,217 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, "gateway.yaml", `
+environment: staging
+apiPort: 8000
+logLevel: warn
+httpReadTimeout: 10s
+corsAllowedOrigins:
+  - https://app.example.com
+  - https://admin.example.com
+sqsQueueUrl: https://sqs/base
+dynamodbTable: gateway
+environments:
+  staging:
+    sqsQueueUrl: https://sqs/staging
+    logLevel: info
+`)
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("USE_LOCAL_SECRETS", "")
+
+	cfg, err := Load([]string{"-config", path, "-api-port", "9000"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "file sets environment", got: cfg.Environment, want: Staging},
+		{name: "flag beats file", got: cfg.ApiPort, want: "9000"},
+		{name: "env beats file", got: cfg.LogLevel, want: "error"},
+		{name: "environment section beats top level", got: cfg.SqsQueueURL, want: "https://sqs/staging"},
+		{name: "file duration", got: cfg.ReadTimeout, want: 10 * time.Second},
+		{name: "file list", got: strings.Join(cfg.CorsAllowedOrigins, ","), want: "https://app.example.com,https://admin.example.com"},
+		{name: "default", got: cfg.WriteTimeout, want: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, tt.got)
+		}
+	}
+
+	// Per-environment variables beat both the plain variable and the file
+	t.Setenv("SQS_QUEUE_URL", "https://sqs/env")
+	t.Setenv("SQS_QUEUE_URL_STAGING", "https://sqs/env-staging")
+	if cfg, err = Load([]string{"-config", path}); err != nil || cfg.SqsQueueURL != "https://sqs/env-staging" {
+		t.Errorf("Expected NAME_<ENV> to win, got %v, %v", cfg, err)
+	}
+}
+
+func TestLoad_JSONFile(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "dev")
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "gateway.json", `{"maxRequestBodyBytes": 1048576, "strictJson": false}`))
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.MaxRequestBodyBytes != 1<<20 || cfg.StrictJSON {
+		t.Errorf("Expected file values, got %d strict=%v", cfg.MaxRequestBodyBytes, cfg.StrictJSON)
+	}
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	path := writeConfigFile(t, "gateway.yaml", `
+sqsQueueURL: https://sqs/typo
+`)
+	t.Setenv("ENVIRONMENT", "prod")
+	t.Setenv("HTTP_READ_TIMEOUT", "soon")
+
+	_, err := Load([]string{"-config", path, "-api-port", "http", "-tls-cert-file", "tls.crt", "-jwks-url", "https://idp/jwks"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+
+	want := []string{
+		`unknown setting "sqsQueueURL"`,
+		`HTTP_READ_TIMEOUT: "soon" is not a non-negative duration`,
+		`API_PORT: "http" is not a valid port`,
+		"TLS_CERT_FILE and TLS_KEY_FILE must be set together",
+		"JWT_ISSUER is required when JWKS_URL is set",
+		"SQS_QUEUE_URL is required in prod",
+		"DYNAMODB_TABLE is required in prod",
+	}
+	msg := err.Error()
+	for _, w := range want {
+		if !strings.Contains(msg, w) {
+			t.Errorf("Expected report to contain %q, got:\n%s", w, msg)
+		}
+	}
+}
+
+func TestLoad_DevDefaultsAreValid(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("DEFAULT_ENVIRONMENT", "dev")
+	if _, err := Load(nil); err != nil {
+		t.Errorf("Expected dev defaults to validate, got %v", err)
+	}
+}
+
+func TestLoad_ReelQuotaZeroDisables(t *testing.T) {
+	path := writeConfigFile(t, "gateway.yaml", "reelQuota: 50\n")
+	t.Setenv("ENVIRONMENT", "dev")
+	t.Setenv("REEL_QUOTA", "")
+
+	if cfg, err := Load([]string{"-config", path}); err != nil || cfg.ReelQuota != 50 {
+		t.Fatalf("Expected the file's quota, got %v, %v", cfg, err)
+	}
+	// A higher layer switches the file's quota off
+	if cfg, err := Load([]string{"-config", path, "-reel-quota", "0"}); err != nil || cfg.ReelQuota != 0 {
+		t.Errorf("Expected -reel-quota=0 to disable the quota, got %v, %v", cfg, err)
+	}
+	t.Setenv("REEL_QUOTA", "0")
+	if cfg, err := Load([]string{"-config", path}); err != nil || cfg.ReelQuota != 0 {
+		t.Errorf("Expected REEL_QUOTA=0 to disable the quota, got %v, %v", cfg, err)
+	}
+	t.Setenv("REEL_QUOTA", "-1")
+	if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "not a non-negative integer") {
+		t.Errorf("Expected a negative quota to fail, got %v", err)
+	}
+}
+
+func TestLoad_Flags(t *testing.T) {
+	if _, err := Load([]string{"-no-such-flag"}); err == nil {
+		t.Error("Expected error for unknown flag")
+	}
+	if _, err := Load([]string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestLoad_Environment(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("DEFAULT_ENVIRONMENT", "")
+	if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "ENVIRONMENT is not set") {
+		t.Errorf("Expected missing ENVIRONMENT to fail, got %v", err)
+	}
+
+	t.Setenv("ENVIRONMENT", "prd")
+	if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), `unknown environment "prd"`) {
+		t.Errorf("Expected unknown ENVIRONMENT to fail, got %v", err)
+	}
+
+	t.Setenv("ENVIRONMENT", "")
+	if cfg, err := Load([]string{"-default-environment", "dev"}); err != nil || cfg.Environment != Dev {
+		t.Errorf("Expected opt-in default, got %v, %v", cfg, err)
+	}
+
+	t.Setenv("ENVIRONMENT", "production")
+	t.Setenv("SQS_QUEUE_URL", "https://sqs/prod")
+	t.Setenv("DYNAMODB_TABLE", "gateway-prod")
+	t.Setenv("USE_LOCAL_SECRETS", "")
+	if cfg, err := Load(nil); err != nil || cfg.Environment != Prod {
+		t.Errorf("Expected production alias to resolve to prod, got %v, %v", cfg, err)
+	}
+}
+
+func TestSettingNames(t *testing.T) {
+	if got := fileKey("OTEL_EXPORTER_OTLP_ENDPOINT"); got != "otelExporterOtlpEndpoint" {
+		t.Errorf("Unexpected file key %q", got)
+	}
+	if got := flagName("SQS_QUEUE_URL"); got != "sqs-queue-url" {
+		t.Errorf("Unexpected flag name %q", got)
+	}
+}
+
+func TestLoad_SecretsProvider(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "dev")
+	t.Setenv("USE_LOCAL_SECRETS", "")
+	t.Setenv("VAULT_TOKEN", "")
+
+	_, err := Load([]string{"-secrets-provider", "vault"})
+	if err == nil || !strings.Contains(err.Error(), "VAULT_ADDR is required") || !strings.Contains(err.Error(), "VAULT_TOKEN is required") {
+		t.Errorf("Expected missing Vault settings to be reported, got %v", err)
+	}
+
+	t.Setenv("VAULT_TOKEN", "token")
+	cfg, err := Load([]string{"-secrets-provider", "vault", "-vault-addr", "https://vault:8200"})
+	if err != nil || cfg.VaultToken != "token" || cfg.VaultKVMount != "secret" || cfg.VaultKVPath != "api-gateway" {
+		t.Errorf("Expected Vault config with defaults, got %+v, %v", cfg, err)
+	}
+
+	t.Setenv("ENVIRONMENT", "prod")
+	t.Setenv("SQS_QUEUE_URL", "https://sqs/prod")
+	if _, err := Load([]string{"-secrets-provider", "env"}); err == nil || !strings.Contains(err.Error(), "SECRETS_PROVIDER=env must not be used in prod") {
+		t.Errorf("Expected env provider to be rejected in prod, got %v", err)
+	}
+
+	t.Setenv("ENVIRONMENT", "dev")
+	t.Setenv("USE_LOCAL_SECRETS", "true")
+	if cfg, err := Load(nil); err != nil || cfg.SecretsProvider != SecretsProviderEnv {
+		t.Errorf("Expected USE_LOCAL_SECRETS to select the env provider, got %+v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// redacted replaces a secret's value wherever it would be printed
+const redacted = "[REDACTED]"
+
+// Secret is a sensitive string that redacts itself in fmt, JSON and slog
+// output, so a stray %v or debug dump cannot leak it. Value returns the
+// plaintext. An empty Secret prints as empty, so a missing secret is visible.
+type Secret string
+
+// Value returns the plaintext secret.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String implements fmt.Stringer.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString implements fmt.GoStringer, for %#v.
+func (s Secret) GoString() string {
+	return fmt.Sprintf("config.Secret(%q)", s.String())
+}
+
+// Format implements fmt.Formatter so every verb, including %s, %q and %x,
+// prints the redacted form.
+func (s Secret) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		io.WriteString(f, s.GoString())
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", s.String())
+	default:
+		io.WriteString(f, s.String())
+	}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// LogValue implements slog.LogValuer.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
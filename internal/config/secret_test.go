@@ -0,0 +1,57 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSecret_Redacts(t *testing.T) {
+	const plaintext = "hunter2-signing-key"
+	secrets := &SecretsConfig{JwtSecret: plaintext, DatabaseURL: "postgres://user:pw@db/gateway"}
+
+	jsonOut, err := json.Marshal(secrets)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var logOut bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logOut, nil)).Info("secrets", "jwt", secrets.JwtSecret, "config", secrets)
+
+	outputs := map[string]string{
+		"%v":      fmt.Sprintf("%v", secrets),
+		"%+v":     fmt.Sprintf("%+v", secrets),
+		"%#v":     fmt.Sprintf("%#v", secrets),
+		"%s":      fmt.Sprintf("%s", secrets.JwtSecret),
+		"%q":      fmt.Sprintf("%q", secrets.JwtSecret),
+		"%x":      fmt.Sprintf("%x", secrets.JwtSecret),
+		"error":   fmt.Errorf("connecting to %v", secrets.DatabaseURL).Error(),
+		"json":    string(jsonOut),
+		"slog":    logOut.String(),
+		"println": fmt.Sprintln(secrets.JwtSecret),
+	}
+	for name, out := range outputs {
+		if strings.Contains(out, plaintext) || strings.Contains(out, "pw@db") {
+			t.Errorf("%s leaked the secret: %s", name, out)
+		}
+		if !strings.Contains(out, redacted) {
+			t.Errorf("%s: expected %s, got %s", name, redacted, out)
+		}
+	}
+
+	if secrets.JwtSecret.Value() != plaintext {
+		t.Errorf("Expected Value to return the plaintext, got %q", secrets.JwtSecret.Value())
+	}
+	if got := fmt.Sprintf("%v", Secret("")); got != "" {
+		t.Errorf("Expected an empty secret to print empty, got %q", got)
+	}
+}
+
+func TestSecret_UnmarshalJSON(t *testing.T) {
+	var secrets SecretsConfig
+	if err := json.Unmarshal([]byte(`{"api-key": "k1"}`), &secrets); err != nil || secrets.ApiKey.Value() != "k1" {
+		t.Errorf("Expected api-key to unmarshal, got %q, %v", secrets.ApiKey.Value(), err)
+	}
+}
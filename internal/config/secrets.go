@@ -3,110 +3,194 @@ package config
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 )
 
-// SecretsConfig holds secrets loaded from AWS Secrets Manager
+// SecretsConfig holds the secrets the gateway runs with. Each is a Secret, so
+// printing, marshalling or logging the struct never reveals a value.
 type SecretsConfig struct {
-	ApiKey            string `json:"api-key"`
-	DatabaseURL       string `json:"database-url"`
-	JwtSecret         string `json:"jwt-secret"`
-	OAuthClientID     string `json:"oauth-client-id"`
-	OAuthClientSecret string `json:"oauth-client-secret"`
+	ApiKey            Secret `json:"api-key"`
+	DatabaseURL       Secret `json:"database-url"`
+	JwtSecret         Secret `json:"jwt-secret"`
+	OAuthClientID     Secret `json:"oauth-client-id"`
+	OAuthClientSecret Secret `json:"oauth-client-secret"`
+	HMACClients       Secret `json:"hmac-clients"`
+
+	// JwtSecretPrevious is the signing key before the last rotation; tokens it
+	// signed are still accepted until they expire
+	JwtSecretPrevious Secret `json:"-"`
+
+	// LoadedAt records when the secrets were fetched, for freshness checks
+	LoadedAt time.Time `json:"-"`
 }
 
-// LoadFromSecretsManager loads secrets from AWS Secrets Manager with environment-specific names
-// For local development only, set USE_LOCAL_SECRETS=true to load from environment variables
-func LoadFromSecretsManager(ctx context.Context) (*SecretsConfig, error) {
-	// Use local secrets only for local development testing
-	// Never use environment variables for production/staging/CI-CD
-	if IsLocalDevelopment() {
-		return loadLocalSecrets()
-	}
+// ErrSecretNotFound is returned by a SecretsProvider when a secret does not exist
+var ErrSecretNotFound = errors.New("secret not found")
 
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("unable to load SDK config: %w", err)
-	}
+// MissingSecretsError lists the required secrets that were not found
+type MissingSecretsError struct {
+	Names []string
+}
 
-	client := secretsmanager.NewFromConfig(cfg)
-	secretsConfig := &SecretsConfig{}
+func (e *MissingSecretsError) Error() string {
+	return "required secrets not found: " + strings.Join(e.Names, ", ")
+}
 
-	// Map of base secret names to their config fields
-	baseSecrets := map[string]*string{
-		"api-key":             &secretsConfig.ApiKey,
-		"database-url":        &secretsConfig.DatabaseURL,
-		"jwt-secret":          &secretsConfig.JwtSecret,
-		"oauth-client-id":     &secretsConfig.OAuthClientID,
-		"oauth-client-secret": &secretsConfig.OAuthClientSecret,
-	}
+// Unwrap lets errors.Is match ErrSecretNotFound
+func (e *MissingSecretsError) Unwrap() error {
+	return ErrSecretNotFound
+}
 
-	currentEnv := GetCurrentEnvironment()
+// SecretsProvider fetches a secret's raw value by name from a backing store
+type SecretsProvider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
 
-	for baseName, field := range baseSecrets {
-		secretName := GetSecretName(baseName)
+// SecretVersion is a secret value and the ID of its version
+type SecretVersion struct {
+	Value     string
+	VersionID string
+}
 
-		// Try environment-specific secret first, then fallback to base name
-		result, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
-			SecretId: aws.String(secretName),
-		})
+// BatchSecretsProvider is implemented by stores that can read many secrets in
+// one call. Names that do not exist are absent from the result; any other
+// per-secret failure is returned as an error alongside the secrets found.
+type BatchSecretsProvider interface {
+	SecretsProvider
+	GetSecrets(ctx context.Context, names []string) (map[string]SecretVersion, error)
+}
 
-		if err != nil {
-			// If environment-specific secret not found, try base name
-			log.Printf("Secret '%s' not found, trying fallback '%s'", secretName, baseName)
-			result, err = client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
-				SecretId: aws.String(baseName),
-			})
+// Version stages of a rotating secret, as used by Secrets Manager
+const (
+	StageCurrent  = "AWSCURRENT"
+	StagePrevious = "AWSPREVIOUS"
+)
 
-			if err != nil {
-				log.Printf("Warning: Secret '%s' (fallback) also not found: %v", baseName, err)
-				continue
-			}
-		}
+// VersionedSecretsProvider is implemented by stores that version secrets, so a
+// refresh can skip unchanged secrets and read the version before a rotation
+type VersionedSecretsProvider interface {
+	SecretsProvider
+	// CurrentVersion returns the version ID of the current version of name
+	CurrentVersion(ctx context.Context, name string) (string, error)
+	// GetSecretVersion returns the value and version ID at stage ("" for current)
+	GetSecretVersion(ctx context.Context, name, stage string) (value, versionID string, err error)
+}
 
-		secretString := ""
-		if result.SecretString != nil {
-			secretString = *result.SecretString
-		}
+// SecretSpec declares a secret the gateway reads and the field it fills
+type SecretSpec struct {
+	// Name is the base secret name; "<name>-<env>" is tried first
+	Name string
+	// Key is the dot-separated path of the value in a JSON object secret, e.g.
+	// "postgres.url"; empty uses Name
+	Key   string
+	Field func(*SecretsConfig) *Secret
+	// Previous, when set, receives the value before the last rotation
+	Previous func(*SecretsConfig) *Secret
+	// Required secrets must exist for the gateway to start
+	Required bool
+}
 
-		// Try to parse as JSON first
-		if secretString != "" {
-			var secretData map[string]interface{}
-			if err := json.Unmarshal([]byte(secretString), &secretData); err == nil {
-				// It's JSON, extract the value
-				if val, ok := secretData[baseName]; ok {
-					*field = fmt.Sprintf("%v", val)
-				} else {
-					*field = secretString
-				}
-			} else {
-				// Not JSON, use as-is
-				*field = secretString
-			}
-		}
+// GatewaySecrets are the secrets loaded at startup
+var GatewaySecrets = []SecretSpec{
+	{Name: "api-key", Field: func(s *SecretsConfig) *Secret { return &s.ApiKey }, Required: true},
+	{Name: "database-url", Field: func(s *SecretsConfig) *Secret { return &s.DatabaseURL }},
+	{Name: "jwt-secret", Field: func(s *SecretsConfig) *Secret { return &s.JwtSecret }, Previous: func(s *SecretsConfig) *Secret { return &s.JwtSecretPrevious }, Required: true},
+	{Name: "oauth-client-id", Field: func(s *SecretsConfig) *Secret { return &s.OAuthClientID }},
+	{Name: "oauth-client-secret", Field: func(s *SecretsConfig) *Secret { return &s.OAuthClientSecret }},
+	{Name: "hmac-clients", Field: func(s *SecretsConfig) *Secret { return &s.HMACClients }},
+}
 
-		log.Printf("Loaded secret '%s' for environment '%s'", secretName, currentEnv)
+// Secrets providers selectable with SECRETS_PROVIDER
+const (
+	SecretsProviderSecretsManager = "aws-secretsmanager"
+	SecretsProviderSSM            = "aws-ssm"
+	SecretsProviderVault          = "vault"
+	SecretsProviderFile           = "file"
+	SecretsProviderEnv            = "env"
+)
+
+// NewSecretsProvider builds the provider selected by envConfig.SecretsProvider.
+// awsCfg is only used by the AWS providers.
+func NewSecretsProvider(envConfig *EnvironmentConfig, awsCfg aws.Config) (SecretsProvider, error) {
+	switch envConfig.SecretsProvider {
+	case SecretsProviderSecretsManager:
+		return NewSecretsManagerProvider(secretsmanager.NewFromConfig(awsCfg)), nil
+	case SecretsProviderSSM:
+		return NewSSMProvider(ssm.NewFromConfig(awsCfg), envConfig.SSMParameterPrefix), nil
+	case SecretsProviderVault:
+		return NewVaultProvider(VaultConfig{
+			Addr:      envConfig.VaultAddr,
+			Token:     envConfig.VaultToken,
+			Namespace: envConfig.VaultNamespace,
+			Mount:     envConfig.VaultKVMount,
+			Path:      envConfig.VaultKVPath,
+		}, nil), nil
+	case SecretsProviderFile:
+		return NewFileProvider(envConfig.SecretsDir), nil
+	case SecretsProviderEnv:
+		// Never use environment variables for production/staging/CI-CD
+		slog.Warn("Loading secrets from environment variables (LOCAL DEVELOPMENT ONLY)")
+		return NewEnvProvider(envConfig.SecretsEnvPrefix), nil
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", envConfig.SecretsProvider)
+	}
+}
+
+// LoadSecrets fetches specs from provider once. See SecretsHolder for how
+// names are resolved and values extracted, and NewSecretsHolder for errors.
+func LoadSecrets(ctx context.Context, provider SecretsProvider, env Environment, specs []SecretSpec) (*SecretsConfig, error) {
+	holder, err := NewSecretsHolder(ctx, provider, env, specs)
+	if err != nil {
+		return nil, err
 	}
+	return holder.Current(), nil
+}
 
-	return secretsConfig, nil
+// key returns the JSON path the spec's value is extracted from
+func (s SecretSpec) key() string {
+	if s.Key != "" {
+		return s.Key
+	}
+	return s.Name
 }
 
-// loadLocalSecrets loads secrets from environment variables (LOCAL DEVELOPMENT ONLY)
-// WARNING: This should NEVER be used in production, staging, or CI/CD environments.
-// It is only for local testing when USE_LOCAL_SECRETS=true is explicitly set.
-func loadLocalSecrets() (*SecretsConfig, error) {
-	log.Println("WARNING: Loading secrets from environment variables (LOCAL DEVELOPMENT ONLY)")
-	return &SecretsConfig{
-		ApiKey:            os.Getenv("LOCAL_API_KEY"),
-		DatabaseURL:       os.Getenv("LOCAL_DATABASE_URL"),
-		JwtSecret:         os.Getenv("LOCAL_JWT_SECRET"),
-		OAuthClientID:     os.Getenv("LOCAL_OAUTH_CLIENT_ID"),
-		OAuthClientSecret: os.Getenv("LOCAL_OAUTH_CLIENT_SECRET"),
-	}, nil
+// extractSecretValue returns the field at the dot-separated path of a JSON
+// object secret, or the value unchanged when it is not a JSON object or lacks
+// the path. Fields that are not strings are returned as JSON.
+func extractSecretValue(value, path string) string {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var node any
+	if err := decoder.Decode(&node); err != nil {
+		return value
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return value
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return value
+		}
+		if node, ok = object[key]; !ok {
+			return value
+		}
+	}
+	if str, ok := node.(string); ok {
+		return str
+	}
+	encoded, err := json.Marshal(node)
+	if err != nil {
+		return value
+	}
+	return string(encoded)
 }
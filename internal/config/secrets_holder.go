@@ -0,0 +1,320 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SecretsHolder keeps the current secrets and refreshes them from a provider,
+// so rotated secrets take effect without a redeploy.
+//
+// For each spec the environment-specific name (e.g. "api-key-prod") is tried
+// before the base name; a value that is a JSON object holding the spec's key
+// is unwrapped to that key's value. Optional secrets that do not exist
+// are logged and left empty.
+//
+// With a VersionedSecretsProvider a refresh only compares version IDs and reads
+// values that changed; Previous fields come from the AWSPREVIOUS stage. Other
+// providers are re-read on every refresh and Previous holds the value replaced
+// by the last change seen.
+type SecretsHolder struct {
+	provider SecretsProvider
+	env      Environment
+	specs    []SecretSpec
+	current  atomic.Pointer[SecretsConfig]
+
+	// mu serializes refreshes and guards the fields below
+	mu          sync.Mutex
+	names       map[string]string // spec name -> resolved secret name
+	versions    map[string]string // spec name -> current version ID
+	subscribers []func(*SecretsConfig)
+}
+
+// secretsFetchConcurrency bounds the secrets read at once during startup
+const secretsFetchConcurrency = 4
+
+// NewSecretsHolder loads specs from provider, reading them concurrently and in
+// batches when the provider is a BatchSecretsProvider. Optional secrets that
+// cannot be read are logged and left empty. If any required secret is missing
+// the error is a *MissingSecretsError naming all of them, joined with any
+// other failure to read a required secret.
+func NewSecretsHolder(ctx context.Context, provider SecretsProvider, env Environment, specs []SecretSpec) (*SecretsHolder, error) {
+	h := &SecretsHolder{
+		provider: provider,
+		env:      env,
+		specs:    specs,
+		names:    make(map[string]string),
+		versions: make(map[string]string),
+	}
+
+	results := make([]fetched, len(specs))
+	errs := make([]error, len(specs))
+	batch := h.fetchBatch(ctx)
+	sem := make(chan struct{}, secretsFetchConcurrency)
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = h.fetch(ctx, spec, batch)
+		}()
+	}
+	wg.Wait()
+
+	secretsConfig := &SecretsConfig{LoadedAt: time.Now()}
+	var missing []string
+	var failed []error
+	for i, spec := range specs {
+		switch err := errs[i]; {
+		case err == nil:
+			h.apply(spec, results[i], secretsConfig)
+		case !spec.Required:
+			slog.Warn("Optional secret not loaded", "secret", spec.Name, "error", err)
+		case errors.Is(err, ErrSecretNotFound):
+			missing = append(missing, spec.Name)
+		default:
+			failed = append(failed, fmt.Errorf("%s: %w", spec.Name, err))
+		}
+	}
+	if len(missing) > 0 {
+		failed = append([]error{&MissingSecretsError{Names: missing}}, failed...)
+	}
+	if err := errors.Join(failed...); err != nil {
+		return nil, err
+	}
+	h.current.Store(secretsConfig)
+	return h, nil
+}
+
+// fetchBatch reads the environment-specific and base name of every spec in
+// one go, or returns nil when the provider cannot or the batch failed.
+func (h *SecretsHolder) fetchBatch(ctx context.Context) map[string]SecretVersion {
+	batcher, ok := h.provider.(BatchSecretsProvider)
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, 2*len(h.specs))
+	for _, spec := range h.specs {
+		names = append(names, GetSecretName(spec.Name, h.env), spec.Name)
+	}
+	batch, err := batcher.GetSecrets(ctx, names)
+	if err != nil {
+		slog.Warn("Batch secrets read failed, reading one at a time", "error", err)
+		return nil
+	}
+	return batch
+}
+
+// Current returns the latest secrets. The returned value is shared and must
+// not be modified.
+func (h *SecretsHolder) Current() *SecretsConfig {
+	return h.current.Load()
+}
+
+// Subscribe registers fn to be called with the new secrets after every
+// refresh that changed a value, such as to re-key authenticators.
+func (h *SecretsHolder) Subscribe(fn func(*SecretsConfig)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers = append(h.subscribers, fn)
+}
+
+// Run refreshes every interval until ctx is cancelled. Failures are logged and
+// the previous values stay in use.
+func (h *SecretsHolder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := h.Refresh(ctx); err != nil {
+				slog.Warn("Secrets refresh failed, keeping current values", "error", err)
+			}
+		}
+	}
+}
+
+// Refresh re-reads changed secrets and atomically swaps in the result. It
+// reports whether any value changed. Secrets that fail to refresh keep their
+// previous value; LoadedAt only advances when every secret was checked.
+// Subscribers are called after the holder is unlocked, so they may use it.
+func (h *SecretsHolder) Refresh(ctx context.Context) (bool, error) {
+	changed, subscribers, err := h.refreshAll(ctx)
+	if changed {
+		// A concurrent refresh may already have swapped in newer secrets;
+		// subscribers always get the latest
+		current := h.Current()
+		for _, fn := range subscribers {
+			fn(current)
+		}
+	}
+	return changed, err
+}
+
+// refreshAll does the work of Refresh under h.mu and returns the subscribers
+// to notify when a value changed.
+func (h *SecretsHolder) refreshAll(ctx context.Context) (bool, []func(*SecretsConfig), error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	old := h.current.Load()
+	next := *old
+	var changed bool
+	var errs []error
+	for _, spec := range h.specs {
+		c, err := h.refresh(ctx, spec, &next)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", spec.Name, err))
+			continue
+		}
+		if c {
+			changed = true
+			slog.Info("Secret rotated", "secret", h.names[spec.Name], "version", h.versions[spec.Name])
+		}
+	}
+	if len(errs) == 0 {
+		next.LoadedAt = time.Now()
+	}
+	if changed || next.LoadedAt != old.LoadedAt {
+		h.current.Store(&next)
+	}
+	return changed, slices.Clone(h.subscribers), errors.Join(errs...)
+}
+
+// fetched is a spec's secret as read from the provider
+type fetched struct {
+	name     string
+	value    string
+	version  string
+	previous *string // nil when not read
+}
+
+// load resolves spec's secret name and reads its value into secretsConfig.
+func (h *SecretsHolder) load(ctx context.Context, spec SecretSpec, secretsConfig *SecretsConfig) (bool, error) {
+	f, err := h.fetch(ctx, spec, nil)
+	if err != nil {
+		return false, err
+	}
+	return h.apply(spec, f, secretsConfig), nil
+}
+
+// fetch resolves spec's secret name and reads it, from batch when not nil. It
+// does not touch the holder's state, so specs can be fetched concurrently.
+func (h *SecretsHolder) fetch(ctx context.Context, spec SecretSpec, batch map[string]SecretVersion) (fetched, error) {
+	// Try environment-specific secret first, then fallback to base name
+	var f fetched
+	var err error
+	for _, name := range []string{GetSecretName(spec.Name, h.env), spec.Name} {
+		f.name = name
+		if batch != nil {
+			v, ok := batch[name]
+			if !ok {
+				err = fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+				continue
+			}
+			f.value, f.version, err = v.Value, v.VersionID, nil
+			break
+		}
+		f.value, f.version, err = h.get(ctx, name, "")
+		if !errors.Is(err, ErrSecretNotFound) {
+			break
+		}
+		if name != spec.Name {
+			slog.Debug("Secret not found, trying fallback", "secret", name, "fallback", spec.Name)
+		}
+	}
+	if err != nil {
+		return fetched{}, err
+	}
+
+	if spec.Previous != nil {
+		if _, ok := h.provider.(VersionedSecretsProvider); ok {
+			previous, _, err := h.get(ctx, f.name, StagePrevious)
+			switch {
+			case err == nil || errors.Is(err, ErrSecretNotFound):
+				f.previous = &previous
+			default:
+				// The current value is still usable; only the rotation overlap is lost
+				slog.Warn("Failed to read previous secret version", "secret", f.name, "error", err)
+			}
+		}
+	}
+	return f, nil
+}
+
+// apply records f as spec's current secret in secretsConfig and reports
+// whether a value changed.
+func (h *SecretsHolder) apply(spec SecretSpec, f fetched, secretsConfig *SecretsConfig) bool {
+	h.names[spec.Name] = f.name
+	h.versions[spec.Name] = f.version
+
+	field := spec.Field(secretsConfig)
+	value := Secret(extractSecretValue(f.value, spec.key()))
+	changed := value != *field
+	*field = value
+	if f.previous != nil {
+		previous := Secret(extractSecretValue(*f.previous, spec.key()))
+		changed = changed || previous != *spec.Previous(secretsConfig)
+		*spec.Previous(secretsConfig) = previous
+	}
+	slog.Info("Loaded secret", "secret", f.name, "environment", h.env.String())
+	return changed
+}
+
+// refresh updates spec in secretsConfig if its secret changed.
+func (h *SecretsHolder) refresh(ctx context.Context, spec SecretSpec, secretsConfig *SecretsConfig) (bool, error) {
+	secretName, ok := h.names[spec.Name]
+	if !ok {
+		// Not found before; it may have been created since
+		changed, err := h.load(ctx, spec, secretsConfig)
+		if errors.Is(err, ErrSecretNotFound) {
+			return false, nil
+		}
+		return changed, err
+	}
+
+	if versioned, ok := h.provider.(VersionedSecretsProvider); ok {
+		version, err := versioned.CurrentVersion(ctx, secretName)
+		if err != nil {
+			return false, err
+		}
+		if version == h.versions[spec.Name] {
+			return false, nil
+		}
+		return h.load(ctx, spec, secretsConfig)
+	}
+
+	value, err := h.provider.GetSecret(ctx, secretName)
+	if err != nil {
+		return false, err
+	}
+	field := spec.Field(secretsConfig)
+	secret := Secret(extractSecretValue(value, spec.key()))
+	if secret == *field {
+		return false, nil
+	}
+	if spec.Previous != nil {
+		*spec.Previous(secretsConfig) = *field
+	}
+	*field = secret
+	return true, nil
+}
+
+// get reads name at stage, with its version ID when the provider has one.
+func (h *SecretsHolder) get(ctx context.Context, name, stage string) (string, string, error) {
+	if versioned, ok := h.provider.(VersionedSecretsProvider); ok {
+		return versioned.GetSecretVersion(ctx, name, stage)
+	}
+	value, err := h.provider.GetSecret(ctx, name)
+	return value, "", err
+}
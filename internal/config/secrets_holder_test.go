@@ -0,0 +1,461 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeSecretsManager stores versioned secrets with AWSCURRENT/AWSPREVIOUS
+// stages and counts calls
+type fakeSecretsManager struct {
+	mu          sync.Mutex
+	secrets     map[string]*fakeSecret
+	err         error
+	batchErr    error
+	describeErr error
+	gets        int
+	describes   int
+	batches     int
+}
+
+type fakeSecret struct {
+	values map[string]string // version ID -> value
+	stages map[string]string // stage -> version ID
+	binary bool              // served as SecretBinary
+}
+
+// put stores value as the new current version of name
+func (f *fakeSecretsManager) put(name, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.secrets == nil {
+		f.secrets = make(map[string]*fakeSecret)
+	}
+	s, ok := f.secrets[name]
+	if !ok {
+		s = &fakeSecret{values: make(map[string]string), stages: make(map[string]string)}
+		f.secrets[name] = s
+	}
+	id := fmt.Sprintf("v%d", len(s.values)+1)
+	s.values[id] = value
+	if current, ok := s.stages[StageCurrent]; ok {
+		s.stages[StagePrevious] = current
+	}
+	s.stages[StageCurrent] = id
+}
+
+// putBinary stores value as the new current version of name, as SecretBinary
+func (f *fakeSecretsManager) putBinary(name, value string) {
+	f.put(name, value)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[name].binary = true
+}
+
+// output returns version id of s as a GetSecretValue result
+func (s *fakeSecret) output(id string) *secretsmanager.GetSecretValueOutput {
+	out := &secretsmanager.GetSecretValueOutput{VersionId: aws.String(id)}
+	if s.binary {
+		out.SecretBinary = []byte(s.values[id])
+	} else {
+		out.SecretString = aws.String(s.values[id])
+	}
+	return out
+}
+
+func (f *fakeSecretsManager) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets++
+	if f.err != nil {
+		return nil, f.err
+	}
+	s, ok := f.secrets[aws.ToString(params.SecretId)]
+	if !ok {
+		return nil, &smtypes.ResourceNotFoundException{}
+	}
+	stage := StageCurrent
+	if params.VersionStage != nil {
+		stage = *params.VersionStage
+	}
+	id, ok := s.stages[stage]
+	if !ok {
+		return nil, &smtypes.ResourceNotFoundException{}
+	}
+	return s.output(id), nil
+}
+
+func (f *fakeSecretsManager) DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.describes++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.describeErr != nil {
+		return nil, f.describeErr
+	}
+	s, ok := f.secrets[aws.ToString(params.SecretId)]
+	if !ok {
+		return nil, &smtypes.ResourceNotFoundException{}
+	}
+	versions := make(map[string][]string)
+	for stage, id := range s.stages {
+		versions[id] = append(versions[id], stage)
+	}
+	return &secretsmanager.DescribeSecretOutput{VersionIdsToStages: versions}, nil
+}
+
+func (f *fakeSecretsManager) BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches++
+	if f.batchErr != nil {
+		return nil, f.batchErr
+	}
+	if len(params.SecretIdList) > batchGetSecretValueLimit {
+		return nil, errors.New("too many secret IDs")
+	}
+	out := &secretsmanager.BatchGetSecretValueOutput{}
+	for _, name := range params.SecretIdList {
+		s, ok := f.secrets[name]
+		if !ok {
+			out.Errors = append(out.Errors, smtypes.APIErrorType{SecretId: aws.String(name), ErrorCode: aws.String("ResourceNotFoundException")})
+			continue
+		}
+		value := s.output(s.stages[StageCurrent])
+		out.SecretValues = append(out.SecretValues, smtypes.SecretValueEntry{
+			Name:         aws.String(name),
+			SecretString: value.SecretString,
+			SecretBinary: value.SecretBinary,
+			VersionId:    value.VersionId,
+		})
+	}
+	return out, nil
+}
+
+func (f *fakeSecretsManager) calls() (gets, describes int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gets, f.describes
+}
+
+func TestSecretsHolder_VersionedRotation(t *testing.T) {
+	client := &fakeSecretsManager{}
+	client.put("api-key-prod", "key-1")
+	client.put("jwt-secret", "signing-1")
+	ctx := context.Background()
+
+	// Only secrets that exist; missing ones are looked up again on every refresh
+	specs := []SecretSpec{GatewaySecrets[0], GatewaySecrets[2]}
+	holder, err := NewSecretsHolder(ctx, NewSecretsManagerProvider(client), Prod, specs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var notified []*SecretsConfig
+	holder.Subscribe(func(s *SecretsConfig) { notified = append(notified, s) })
+	loadedAt := holder.Current().LoadedAt
+
+	// Unchanged versions are only described, never read
+	gets, _ := client.calls()
+	changed, err := holder.Refresh(ctx)
+	if err != nil || changed {
+		t.Fatalf("Expected no change, got %v, %v", changed, err)
+	}
+	if after, _ := client.calls(); after != gets {
+		t.Errorf("Expected no secret reads for unchanged versions, got %d", after-gets)
+	}
+	if !holder.Current().LoadedAt.After(loadedAt) {
+		t.Error("Expected LoadedAt to advance after a successful refresh")
+	}
+	if len(notified) != 0 {
+		t.Errorf("Expected no notifications, got %d", len(notified))
+	}
+
+	old := holder.Current()
+	client.put("jwt-secret", "signing-2")
+	changed, err = holder.Refresh(ctx)
+	if err != nil || !changed {
+		t.Fatalf("Expected a change, got %v, %v", changed, err)
+	}
+	current := holder.Current()
+	if current.JwtSecret.Value() != "signing-2" || current.JwtSecretPrevious.Value() != "signing-1" {
+		t.Errorf("Expected rotated jwt-secret with previous, got %q, %q", current.JwtSecret.Value(), current.JwtSecretPrevious.Value())
+	}
+	if current.ApiKey.Value() != "key-1" {
+		t.Errorf("Expected api-key to be kept, got %q", current.ApiKey.Value())
+	}
+	if old.JwtSecret.Value() != "signing-1" {
+		t.Error("Expected the previous SecretsConfig to be left unmodified")
+	}
+	if len(notified) != 1 || notified[0] != current {
+		t.Errorf("Expected one notification with the new secrets, got %d", len(notified))
+	}
+}
+
+func TestSecretsHolder_SubscriberMayUseHolder(t *testing.T) {
+	provider := mapProvider{"jwt-secret": "signing-1", "api-key": "key-1"}
+	ctx := context.Background()
+
+	holder, err := NewSecretsHolder(ctx, provider, Dev, GatewaySecrets)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Subscribers run without the holder's lock, so they may read it, subscribe
+	// or even refresh without deadlocking
+	var seen string
+	holder.Subscribe(func(s *SecretsConfig) {
+		seen = holder.Current().JwtSecret.Value()
+		holder.Subscribe(func(*SecretsConfig) {})
+		if _, err := holder.Refresh(ctx); err != nil {
+			t.Errorf("Unexpected error refreshing from a subscriber: %v", err)
+		}
+	})
+
+	provider["jwt-secret"] = "signing-2"
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		holder.Refresh(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Refresh deadlocked calling a subscriber")
+	}
+	if seen != "signing-2" {
+		t.Errorf("Expected the subscriber to see the new secret, got %q", seen)
+	}
+}
+
+func TestSecretsHolder_DescribeSecretDenied(t *testing.T) {
+	client := &fakeSecretsManager{describeErr: &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to perform secretsmanager:DescribeSecret"}}
+	client.put("api-key", "key-1")
+	client.put("jwt-secret", "signing-1")
+	ctx := context.Background()
+
+	holder, err := NewSecretsHolder(ctx, NewSecretsManagerProvider(client), Prod, []SecretSpec{GatewaySecrets[0], GatewaySecrets[2]})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Without DescribeSecret, rotations are still found by reading the values
+	client.put("jwt-secret", "signing-2")
+	changed, err := holder.Refresh(ctx)
+	if err != nil || !changed {
+		t.Fatalf("Expected a change despite DescribeSecret being denied, got %v, %v", changed, err)
+	}
+	if got := holder.Current().JwtSecret.Value(); got != "signing-2" {
+		t.Errorf("Expected rotated jwt-secret, got %q", got)
+	}
+
+	// DescribeSecret is not retried once refused
+	_, describes := client.calls()
+	if _, err := holder.Refresh(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, after := client.calls(); after != describes {
+		t.Errorf("Expected no further DescribeSecret calls, got %d", after-describes)
+	}
+}
+
+func TestSecretsHolder_RefreshFailureKeepsValues(t *testing.T) {
+	client := &fakeSecretsManager{}
+	client.put("api-key", "key-1")
+	client.put("jwt-secret", "signing-1")
+	ctx := context.Background()
+
+	holder, _ := NewSecretsHolder(ctx, NewSecretsManagerProvider(client), Prod, GatewaySecrets)
+	before := holder.Current()
+
+	client.err = errors.New("throttled")
+	changed, err := holder.Refresh(ctx)
+	if err == nil || changed {
+		t.Fatalf("Expected refresh error, got %v, %v", changed, err)
+	}
+	if got := holder.Current(); got.ApiKey.Value() != "key-1" || !got.LoadedAt.Equal(before.LoadedAt) {
+		t.Errorf("Expected old values and LoadedAt to be kept, got %q at %v", got.ApiKey.Value(), got.LoadedAt)
+	}
+}
+
+func TestSecretsHolder_NonVersionedProvider(t *testing.T) {
+	provider := mapProvider{"jwt-secret": "signing-1", "api-key": "key-1"}
+	ctx := context.Background()
+
+	holder, _ := NewSecretsHolder(ctx, provider, Dev, GatewaySecrets)
+	var notified int
+	holder.Subscribe(func(*SecretsConfig) { notified++ })
+
+	if changed, err := holder.Refresh(ctx); err != nil || changed {
+		t.Fatalf("Expected no change, got %v, %v", changed, err)
+	}
+
+	provider["jwt-secret"] = "signing-2"
+	provider["database-url-dev"] = "postgres://db" // created after startup
+	changed, err := holder.Refresh(ctx)
+	if err != nil || !changed {
+		t.Fatalf("Expected a change, got %v, %v", changed, err)
+	}
+
+	current := holder.Current()
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "rotated value", got: current.JwtSecret.Value(), want: "signing-2"},
+		{name: "previous value kept in process", got: current.JwtSecretPrevious.Value(), want: "signing-1"},
+		{name: "secret created later picked up", got: current.DatabaseURL.Value(), want: "postgres://db"},
+		{name: "unchanged secret", got: current.ApiKey.Value(), want: "key-1"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, tt.got)
+		}
+	}
+	if notified != 1 {
+		t.Errorf("Expected one notification, got %d", notified)
+	}
+}
+
+func TestNewSecretsHolder_RequiredSecrets(t *testing.T) {
+	required := func(name string) SecretSpec {
+		return SecretSpec{Name: name, Field: func(s *SecretsConfig) *Secret { return &s.ApiKey }, Required: true}
+	}
+	optional := SecretSpec{Name: "database-url", Field: func(s *SecretsConfig) *Secret { return &s.DatabaseURL }}
+
+	tests := []struct {
+		name        string
+		provider    SecretsProvider
+		specs       []SecretSpec
+		wantMissing []string
+		wantErr     string
+	}{
+		{
+			name:     "optional secret may be missing",
+			provider: mapProvider{"api-key": "key"},
+			specs:    []SecretSpec{required("api-key"), optional},
+		},
+		{
+			name:        "every missing required secret listed",
+			provider:    mapProvider{"api-key": "key"},
+			specs:       []SecretSpec{required("jwt-secret"), required("api-key"), required("hmac-clients"), optional},
+			wantMissing: []string{"jwt-secret", "hmac-clients"},
+		},
+		{
+			name:     "required secret read failure",
+			provider: failingProvider{errors.New("access denied")},
+			specs:    []SecretSpec{required("api-key"), optional},
+			wantErr:  "access denied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			holder, err := NewSecretsHolder(context.Background(), tt.provider, Prod, tt.specs)
+			var missing *MissingSecretsError
+			switch {
+			case tt.wantMissing != nil:
+				if !errors.As(err, &missing) || !slices.Equal(missing.Names, tt.wantMissing) {
+					t.Fatalf("Expected missing %v, got %v", tt.wantMissing, err)
+				}
+				if !errors.Is(err, ErrSecretNotFound) {
+					t.Error("Expected MissingSecretsError to match ErrSecretNotFound")
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || errors.As(err, &missing) {
+					t.Fatalf("Expected %q error, got %v", tt.wantErr, err)
+				}
+			default:
+				if err != nil || holder.Current() == nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// failingProvider fails every read with err
+type failingProvider struct{ err error }
+
+func (p failingProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	return "", p.err
+}
+
+// slowProvider records how many reads run at once
+type slowProvider struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (p *slowProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	p.mu.Lock()
+	p.inFlight++
+	p.peak = max(p.peak, p.inFlight)
+	p.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+	return "value-of-" + name, nil
+}
+
+func TestNewSecretsHolder_ConcurrentFetch(t *testing.T) {
+	var specs []SecretSpec
+	values := make([]Secret, 3*secretsFetchConcurrency)
+	for i := range values {
+		specs = append(specs, SecretSpec{Name: fmt.Sprintf("secret-%d", i), Field: func(*SecretsConfig) *Secret { return &values[i] }})
+	}
+	provider := &slowProvider{}
+
+	if _, err := NewSecretsHolder(context.Background(), provider, Dev, specs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if provider.peak < 2 || provider.peak > secretsFetchConcurrency {
+		t.Errorf("Expected between 2 and %d concurrent reads, got %d", secretsFetchConcurrency, provider.peak)
+	}
+	for i, v := range values {
+		if want := fmt.Sprintf("value-of-secret-%d-dev", i); v.Value() != want {
+			t.Errorf("Expected %q, got %q", want, v.Value())
+		}
+	}
+}
+
+func TestNewSecretsHolder_Batch(t *testing.T) {
+	client := &fakeSecretsManager{}
+	client.put("api-key-prod", "key-1")
+	client.put("jwt-secret", "signing-1")
+	client.put("jwt-secret", "signing-2")
+	ctx := context.Background()
+
+	holder, err := NewSecretsHolder(ctx, NewSecretsManagerProvider(client), Prod, GatewaySecrets)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	current := holder.Current()
+	if current.ApiKey.Value() != "key-1" || current.JwtSecret.Value() != "signing-2" || current.JwtSecretPrevious.Value() != "signing-1" {
+		t.Errorf("Unexpected secrets from batch: %q, %q, %q", current.ApiKey.Value(), current.JwtSecret.Value(), current.JwtSecretPrevious.Value())
+	}
+	// One batch call; only the previous jwt-secret version is read on its own
+	if gets, _ := client.calls(); client.batches != 1 || gets != 1 {
+		t.Errorf("Expected 1 batch and 1 single read, got %d and %d", client.batches, gets)
+	}
+
+	// A failed batch, e.g. for lack of permission, falls back to single reads
+	client.batchErr = errors.New("AccessDeniedException")
+	holder, err = NewSecretsHolder(ctx, NewSecretsManagerProvider(client), Prod, GatewaySecrets)
+	if err != nil || holder.Current().ApiKey.Value() != "key-1" {
+		t.Fatalf("Expected fallback to single reads, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+)
+
+// SecretsManagerAPI defines the Secrets Manager operations used (for testing)
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	BatchGetSecretValue(ctx context.Context, params *secretsmanager.BatchGetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error)
+	DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
+}
+
+// SecretsManagerProvider reads secrets from AWS Secrets Manager. Secrets
+// stored as SecretBinary are returned as their raw bytes.
+type SecretsManagerProvider struct {
+	client SecretsManagerAPI
+	// describeDenied is set once DescribeSecret is refused; CurrentVersion
+	// then reads the secret value to learn its version
+	describeDenied atomic.Bool
+}
+
+// NewSecretsManagerProvider creates a provider backed by client
+func NewSecretsManagerProvider(client SecretsManagerAPI) *SecretsManagerProvider {
+	return &SecretsManagerProvider{client: client}
+}
+
+// GetSecret implements SecretsProvider
+func (p *SecretsManagerProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	value, _, err := p.GetSecretVersion(ctx, name, "")
+	return value, err
+}
+
+// GetSecretVersion implements VersionedSecretsProvider
+func (p *SecretsManagerProvider) GetSecretVersion(ctx context.Context, name, stage string) (string, string, error) {
+	input := &secretsmanager.GetSecretValueInput{SecretId: aws.String(name)}
+	if stage != "" {
+		input.VersionStage = aws.String(stage)
+	}
+	out, err := p.client.GetSecretValue(ctx, input)
+	if err != nil {
+		return "", "", secretsManagerError(err, name)
+	}
+	return secretsManagerValue(out.SecretString, out.SecretBinary), aws.ToString(out.VersionId), nil
+}
+
+// CurrentVersion implements VersionedSecretsProvider with DescribeSecret,
+// which does not read the secret value. When the caller lacks
+// secretsmanager:DescribeSecret it falls back to GetSecretValue.
+func (p *SecretsManagerProvider) CurrentVersion(ctx context.Context, name string) (string, error) {
+	if !p.describeDenied.Load() {
+		out, err := p.client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(name)})
+		if err == nil {
+			for id, stages := range out.VersionIdsToStages {
+				if slices.Contains(stages, StageCurrent) {
+					return id, nil
+				}
+			}
+			return "", fmt.Errorf("%w: %s has no %s version", ErrSecretNotFound, name, StageCurrent)
+		}
+		if !isAccessDenied(err) {
+			return "", secretsManagerError(err, name)
+		}
+		if p.describeDenied.CompareAndSwap(false, true) {
+			slog.Warn("DescribeSecret is not permitted, reading secret values to detect rotation", "secret", name, "error", err)
+		}
+	}
+	_, version, err := p.GetSecretVersion(ctx, name, "")
+	return version, err
+}
+
+// batchGetSecretValueLimit is the most secret IDs BatchGetSecretValue accepts
+const batchGetSecretValueLimit = 20
+
+// GetSecrets implements BatchSecretsProvider with BatchGetSecretValue. The
+// caller needs secretsmanager:BatchGetSecretValue in addition to GetSecretValue.
+func (p *SecretsManagerProvider) GetSecrets(ctx context.Context, names []string) (map[string]SecretVersion, error) {
+	found := make(map[string]SecretVersion, len(names))
+	var errs []error
+	for chunk := range slices.Chunk(names, batchGetSecretValueLimit) {
+		out, err := p.client.BatchGetSecretValue(ctx, &secretsmanager.BatchGetSecretValueInput{SecretIdList: chunk})
+		if err != nil {
+			return found, err
+		}
+		for _, entry := range out.SecretValues {
+			found[aws.ToString(entry.Name)] = SecretVersion{Value: secretsManagerValue(entry.SecretString, entry.SecretBinary), VersionID: aws.ToString(entry.VersionId)}
+		}
+		for _, e := range out.Errors {
+			if aws.ToString(e.ErrorCode) != "ResourceNotFoundException" {
+				errs = append(errs, fmt.Errorf("%s: %s: %s", aws.ToString(e.SecretId), aws.ToString(e.ErrorCode), aws.ToString(e.Message)))
+			}
+		}
+	}
+	return found, errors.Join(errs...)
+}
+
+// secretsManagerValue returns a secret's string value, or its binary value
+// (already base64-decoded by the SDK) for secrets stored as SecretBinary
+func secretsManagerValue(str *string, binary []byte) string {
+	if str != nil {
+		return *str
+	}
+	return string(binary)
+}
+
+// isAccessDenied reports whether an AWS call was refused by IAM
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException"
+}
+
+func secretsManagerError(err error, name string) error {
+	var notFound *smtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return err
+}
+
+// SSMAPI defines the SSM Parameter Store operations used (for testing)
+type SSMAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// SSMProvider reads secrets from SSM Parameter Store as prefix+name,
+// decrypting SecureString parameters
+type SSMProvider struct {
+	client SSMAPI
+	prefix string
+}
+
+// NewSSMProvider creates a provider for parameters under prefix, e.g. "/api-gateway/"
+func NewSSMProvider(client SSMAPI, prefix string) *SSMProvider {
+	return &SSMProvider{client: client, prefix: prefix}
+}
+
+// GetSecret implements SecretsProvider
+func (p *SSMProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	out, err := p.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(p.prefix + name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		var notFound *ssmtypes.ParameterNotFound
+		if errors.As(err, &notFound) {
+			return "", fmt.Errorf("%w: %s%s", ErrSecretNotFound, p.prefix, name)
+		}
+		return "", err
+	}
+	if out.Parameter == nil {
+		return "", fmt.Errorf("%w: %s%s", ErrSecretNotFound, p.prefix, name)
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
+
+// VaultConfig locates secrets in a HashiCorp Vault KV version 2 engine
+type VaultConfig struct {
+	Addr      string // e.g. https://vault.internal:8200
+	Token     string
+	Namespace string // Vault Enterprise namespace, optional
+	Mount     string // KV engine mount, e.g. "secret"
+	Path      string // directory under the mount holding one entry per secret
+}
+
+// VaultProvider reads secrets from Vault KV v2. Each secret is an entry at
+// <mount>/<path>/<name>; its "value" field is used when present, otherwise
+// all fields are returned as a JSON object.
+type VaultProvider struct {
+	cfg    VaultConfig
+	client *http.Client
+}
+
+// NewVaultProvider creates a Vault provider; a nil client uses a 10 second timeout
+func NewVaultProvider(cfg VaultConfig, client *http.Client) *VaultProvider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &VaultProvider{cfg: cfg, client: client}
+}
+
+// GetSecret implements SecretsProvider
+func (p *VaultProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	endpoint, err := url.JoinPath(p.cfg.Addr, "v1", p.cfg.Mount, "data", p.cfg.Path, name)
+	if err != nil {
+		return "", fmt.Errorf("building vault URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.cfg.Token)
+	if p.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.cfg.Namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("reading vault secret %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("reading vault secret %s: unexpected status %d", name, resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding vault secret %s: %w", name, err)
+	}
+	// A deleted latest version reads as 200 with null data
+	if body.Data.Data == nil {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	if value, ok := body.Data.Data["value"].(string); ok {
+		return value, nil
+	}
+	fields, err := json.Marshal(body.Data.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(fields), nil
+}
+
+// FileProvider reads each secret from a file named after it in a directory,
+// as mounted by Kubernetes secrets or the Vault agent
+type FileProvider struct {
+	dir string
+}
+
+// NewFileProvider creates a provider reading files from dir
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{dir: dir}
+}
+
+// GetSecret implements SecretsProvider. A single trailing newline is trimmed.
+func (p *FileProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(p.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+// EnvProvider reads secrets from environment variables: "api-key-dev" is
+// <prefix>API_KEY_DEV. For local development only.
+type EnvProvider struct {
+	prefix string
+}
+
+// NewEnvProvider creates a provider reading variables with prefix, e.g. "LOCAL_"
+func NewEnvProvider(prefix string) *EnvProvider {
+	return &EnvProvider{prefix: prefix}
+}
+
+// GetSecret implements SecretsProvider
+func (p *EnvProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	variable := p.prefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	value, ok := os.LookupEnv(variable)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, variable)
+	}
+	return value, nil
+}
@@ -0,0 +1,360 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// mapProvider serves secrets from a map
+type mapProvider map[string]string
+
+func (m mapProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	if v, ok := m[name]; ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+}
+
+func TestLoadSecrets(t *testing.T) {
+	provider := mapProvider{
+		"api-key-prod":    "prod-key",
+		"api-key":         "base-key",
+		"jwt-secret":      `{"jwt-secret": "signing-key"}`,
+		"oauth-client-id": `{"other": "value"}`,
+	}
+
+	secrets, err := LoadSecrets(context.Background(), provider, Prod, GatewaySecrets)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "environment-specific name wins", got: secrets.ApiKey.Value(), want: "prod-key"},
+		{name: "JSON unwrapped by base name", got: secrets.JwtSecret.Value(), want: "signing-key"},
+		{name: "JSON without the key kept whole", got: secrets.OAuthClientID.Value(), want: `{"other": "value"}`},
+		{name: "missing secret left empty", got: secrets.DatabaseURL.Value(), want: ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, tt.got)
+		}
+	}
+	if secrets.LoadedAt.IsZero() {
+		t.Error("Expected LoadedAt to be set")
+	}
+}
+
+func TestExtractSecretValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		path  string
+		want  string
+	}{
+		{name: "plain string", value: "s3cret", path: "api-key", want: "s3cret"},
+		{name: "top-level key", value: `{"api-key": "k1", "other": "x"}`, path: "api-key", want: "k1"},
+		{name: "missing key kept whole", value: `{"other": "x"}`, path: "api-key", want: `{"other": "x"}`},
+		{name: "nested key", value: `{"postgres": {"url": "postgres://db"}}`, path: "postgres.url", want: "postgres://db"},
+		{name: "missing nested key kept whole", value: `{"postgres": {}}`, path: "postgres.url", want: `{"postgres": {}}`},
+		{name: "path through non-object kept whole", value: `{"postgres": "x"}`, path: "postgres.url", want: `{"postgres": "x"}`},
+		{name: "object field as JSON", value: `{"hmac-clients": [{"id": "a"}]}`, path: "hmac-clients", want: `[{"id":"a"}]`},
+		{name: "large number kept exact", value: `{"id": 12345678901234567890}`, path: "id", want: "12345678901234567890"},
+		{name: "JSON string not unwrapped", value: `"quoted"`, path: "api-key", want: `"quoted"`},
+		{name: "trailing data kept whole", value: `{"api-key": "k1"} tail`, path: "api-key", want: `{"api-key": "k1"} tail`},
+	}
+	for _, tt := range tests {
+		if got := extractSecretValue(tt.value, tt.path); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSecretsManagerProvider(t *testing.T) {
+	client := &fakeSecretsManager{}
+	client.put("api-key", "key-1")
+	client.put("api-key", "key-2")
+	client.putBinary("tls-key", "\x00binary")
+	provider := NewSecretsManagerProvider(client)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		secret      string
+		stage       string
+		want        string
+		wantVersion string
+		wantErr     error
+	}{
+		{name: "current string", secret: "api-key", want: "key-2", wantVersion: "v2"},
+		{name: "previous stage", secret: "api-key", stage: StagePrevious, want: "key-1", wantVersion: "v1"},
+		{name: "binary", secret: "tls-key", want: "\x00binary", wantVersion: "v1"},
+		{name: "missing secret", secret: "jwt-secret", wantErr: ErrSecretNotFound},
+		{name: "missing stage", secret: "tls-key", stage: StagePrevious, wantErr: ErrSecretNotFound},
+	}
+	for _, tt := range tests {
+		got, version, err := provider.GetSecretVersion(ctx, tt.secret, tt.stage)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want || version != tt.wantVersion {
+			t.Errorf("%s: expected %q at %s, got %q at %s, %v", tt.name, tt.want, tt.wantVersion, got, version, err)
+		}
+	}
+
+	if version, err := provider.CurrentVersion(ctx, "api-key"); err != nil || version != "v2" {
+		t.Errorf("Expected current version v2, got %q, %v", version, err)
+	}
+	if batch, err := provider.GetSecrets(ctx, []string{"api-key", "tls-key", "jwt-secret"}); err != nil || len(batch) != 2 || batch["tls-key"].Value != "\x00binary" {
+		t.Errorf("Expected two secrets from batch, got %v, %v", batch, err)
+	}
+
+	client.err = errors.New("throttled")
+	if _, err := provider.GetSecret(ctx, "api-key"); err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected throttling error, got %v", err)
+	}
+}
+
+func TestLoadSecrets_SecretsManager(t *testing.T) {
+	spec := func(key string) []SecretSpec {
+		return []SecretSpec{{Name: "database-url", Key: key, Field: func(s *SecretsConfig) *Secret { return &s.DatabaseURL }}}
+	}
+	tests := []struct {
+		name  string
+		setup func(*fakeSecretsManager)
+		specs []SecretSpec
+		want  string
+	}{
+		{
+			name:  "environment-specific name wins",
+			setup: func(f *fakeSecretsManager) { f.put("database-url-prod", "prod-url"); f.put("database-url", "base-url") },
+			want:  "prod-url",
+		},
+		{
+			name:  "falls back to base name",
+			setup: func(f *fakeSecretsManager) { f.put("database-url", "base-url") },
+			want:  "base-url",
+		},
+		{
+			name:  "JSON unwrapped by base name",
+			setup: func(f *fakeSecretsManager) { f.put("database-url-prod", `{"database-url": "json-url"}`) },
+			want:  "json-url",
+		},
+		{
+			name:  "JSON without the key kept whole",
+			setup: func(f *fakeSecretsManager) { f.put("database-url", `{"url": "x"}`) },
+			want:  `{"url": "x"}`,
+		},
+		{
+			name:  "nested key",
+			setup: func(f *fakeSecretsManager) { f.put("database-url", `{"postgres": {"url": "nested-url"}}`) },
+			specs: spec("postgres.url"),
+			want:  "nested-url",
+		},
+		{
+			name:  "binary secret",
+			setup: func(f *fakeSecretsManager) { f.putBinary("database-url-prod", "binary-url") },
+			want:  "binary-url",
+		},
+		{
+			name:  "binary JSON secret",
+			setup: func(f *fakeSecretsManager) { f.putBinary("database-url", `{"database-url": "binary-json-url"}`) },
+			want:  "binary-json-url",
+		},
+		{
+			name:  "missing optional secret left empty",
+			setup: func(f *fakeSecretsManager) {},
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		for _, batch := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/batch=%v", tt.name, batch), func(t *testing.T) {
+				client := &fakeSecretsManager{}
+				tt.setup(client)
+				if !batch {
+					client.batchErr = errors.New("AccessDeniedException")
+				}
+				specs := tt.specs
+				if specs == nil {
+					specs = spec("")
+				}
+				secrets, err := LoadSecrets(context.Background(), NewSecretsManagerProvider(client), Prod, specs)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if secrets.DatabaseURL.Value() != tt.want {
+					t.Errorf("Expected %q, got %q", tt.want, secrets.DatabaseURL.Value())
+				}
+			})
+		}
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	entries := map[string]any{
+		"api-key":    map[string]any{"value": "vault-key"},
+		"jwt-secret": map[string]any{"jwt-secret": "signing-key", "previous": "old-key"},
+		"deleted":    nil,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" || r.Header.Get("X-Vault-Namespace") != "platform" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		name, ok := cutVaultPath(r.URL.Path)
+		data, exists := entries[name]
+		if !ok || !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": data, "metadata": map[string]any{"version": 3}}})
+	}))
+	defer server.Close()
+
+	provider := NewVaultProvider(VaultConfig{Addr: server.URL, Token: "test-token", Namespace: "platform", Mount: "secret", Path: "api-gateway"}, server.Client())
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{name: "api-key", want: "vault-key"},
+		{name: "jwt-secret", want: `{"jwt-secret":"signing-key","previous":"old-key"}`},
+		{name: "deleted", wantErr: ErrSecretNotFound},
+		{name: "missing", wantErr: ErrSecretNotFound},
+	}
+	for _, tt := range tests {
+		got, err := provider.GetSecret(context.Background(), tt.name)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: expected %v, got %q, %v", tt.name, tt.wantErr, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: expected %q, got %q, %v", tt.name, tt.want, got, err)
+		}
+	}
+
+	// A bad token is an error, not a missing secret
+	denied := NewVaultProvider(VaultConfig{Addr: server.URL, Token: "wrong", Mount: "secret", Path: "api-gateway"}, server.Client())
+	if _, err := denied.GetSecret(context.Background(), "api-key"); err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected permission error, got %v", err)
+	}
+}
+
+func cutVaultPath(path string) (string, bool) {
+	const prefix = "/v1/secret/data/api-gateway/"
+	if len(path) <= len(prefix) || path[:len(prefix)] != prefix {
+		return "", false
+	}
+	return path[len(prefix):], true
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "api-key"), []byte("file-key\n"), 0o600)
+	provider := NewFileProvider(dir)
+
+	if got, err := provider.GetSecret(context.Background(), "api-key"); err != nil || got != "file-key" {
+		t.Errorf("Expected trimmed file contents, got %q, %v", got, err)
+	}
+	if _, err := provider.GetSecret(context.Background(), "jwt-secret"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+	if _, err := provider.GetSecret(context.Background(), "../etc/passwd"); err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected invalid name error, got %v", err)
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("LOCAL_API_KEY_DEV", "dev-key")
+	provider := NewEnvProvider("LOCAL_")
+
+	if got, err := provider.GetSecret(context.Background(), "api-key-dev"); err != nil || got != "dev-key" {
+		t.Errorf("Expected dev-key, got %q, %v", got, err)
+	}
+	if _, err := provider.GetSecret(context.Background(), "jwt-secret"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+type fakeSSMClient struct {
+	params map[string]string
+	err    error
+}
+
+func (f *fakeSSMClient) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if !aws.ToBool(params.WithDecryption) {
+		return nil, errors.New("expected decryption")
+	}
+	v, ok := f.params[aws.ToString(params.Name)]
+	if !ok {
+		return nil, &ssmtypes.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Value: aws.String(v)}}, nil
+}
+
+func TestSSMProvider(t *testing.T) {
+	client := &fakeSSMClient{params: map[string]string{"/api-gateway/api-key-prod": "ssm-key"}}
+	provider := NewSSMProvider(client, "/api-gateway/")
+
+	if got, err := provider.GetSecret(context.Background(), "api-key-prod"); err != nil || got != "ssm-key" {
+		t.Errorf("Expected ssm-key, got %q, %v", got, err)
+	}
+	if _, err := provider.GetSecret(context.Background(), "api-key"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound, got %v", err)
+	}
+
+	client.err = errors.New("throttled")
+	if _, err := provider.GetSecret(context.Background(), "api-key-prod"); err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected throttling error, got %v", err)
+	}
+}
+
+func TestNewSecretsProvider(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     string
+		wantErr  bool
+	}{
+		{provider: SecretsProviderSecretsManager, want: "*config.SecretsManagerProvider"},
+		{provider: SecretsProviderSSM, want: "*config.SSMProvider"},
+		{provider: SecretsProviderVault, want: "*config.VaultProvider"},
+		{provider: SecretsProviderFile, want: "*config.FileProvider"},
+		{provider: SecretsProviderEnv, want: "*config.EnvProvider"},
+		{provider: "keychain", wantErr: true},
+	}
+	for _, tt := range tests {
+		p, err := NewSecretsProvider(&EnvironmentConfig{SecretsProvider: tt.provider}, aws.Config{})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.provider)
+			}
+			continue
+		}
+		if got := fmt.Sprintf("%T", p); err != nil || got != tt.want {
+			t.Errorf("%s: expected %s, got %s, %v", tt.provider, tt.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package cors lets browser clients on allowed origins call the gateway
+// directly.
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config lists what cross-origin callers may do.
+type Config struct {
+	// AllowedOrigins are exact origins such as "https://app.example.com",
+	// subdomain wildcards such as "https://*.example.com", or "*" for any
+	// origin. CORS is disabled when empty.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight result.
+	MaxAge time.Duration
+}
+
+// CORS applies a Config to requests.
+type CORS struct {
+	cfg       Config
+	anyOrigin bool
+	methods   string
+	headers   string
+	exposed   string
+	maxAge    string
+}
+
+// New validates cfg. Credentials cannot be combined with the "*" origin,
+// which would let any site make authenticated calls.
+func New(cfg Config) (*CORS, error) {
+	c := &CORS{
+		cfg:       cfg,
+		anyOrigin: slices.Contains(cfg.AllowedOrigins, "*"),
+		methods:   strings.Join(upper(cfg.AllowedMethods), ", "),
+		headers:   strings.Join(cfg.AllowedHeaders, ", "),
+		exposed:   strings.Join(cfg.ExposedHeaders, ", "),
+	}
+	if c.anyOrigin && cfg.AllowCredentials {
+		return nil, errors.New(`cors: credentials cannot be allowed for origin "*"`)
+	}
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	return c, nil
+}
+
+// Middleware answers preflight requests itself, without authentication, and
+// adds CORS headers to the actual requests of allowed origins. Requests from
+// other origins are served without CORS headers, so browsers block them.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	if len(c.cfg.AllowedOrigins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r, origin)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if c.allowOrigin(w, origin) && c.exposed != "" {
+			w.Header().Set("Access-Control-Expose-Headers", c.exposed)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if c.methodAllowed(method) && c.headersAllowed(r.Header.Get("Access-Control-Request-Headers")) && c.allowOrigin(w, origin) {
+		h.Set("Access-Control-Allow-Methods", c.methods)
+		if c.headers != "" {
+			h.Set("Access-Control-Allow-Headers", c.headers)
+		}
+		if c.maxAge != "" {
+			h.Set("Access-Control-Max-Age", c.maxAge)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowOrigin sets the allow-origin headers when origin is allowed.
+func (c *CORS) allowOrigin(w http.ResponseWriter, origin string) bool {
+	switch {
+	case c.anyOrigin:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	case c.originAllowed(origin):
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if c.cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (c *CORS) originAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.cfg.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == origin {
+			return true
+		}
+		// "https://*.example.com" matches "https://app.example.com" but not "https://example.com"
+		if scheme, domain, ok := strings.Cut(allowed, "://*."); ok {
+			host, found := strings.CutPrefix(origin, scheme+"://")
+			if found && strings.HasSuffix(host, "."+domain) && !strings.Contains(host, "/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *CORS) methodAllowed(method string) bool {
+	return method != "" && slices.Contains(upper(c.cfg.AllowedMethods), method)
+}
+
+// headersAllowed reports whether every requested header is allowed;
+// header names are case-insensitive.
+func (c *CORS) headersAllowed(requested string) bool {
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.ContainsFunc(c.cfg.AllowedHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, name)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+func upper(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToUpper(v)
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestCORS(t *testing.T, credentials bool) http.Handler {
+	t.Helper()
+	c, err := New(Config{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.preview.example.com"},
+		AllowedMethods:   []string{"GET", "post"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: credentials,
+		MaxAge:           10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+func TestMiddleware_Preflight(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{name: "allowed", origin: "https://app.example.com", method: "POST", headers: "content-type, authorization", allowed: true},
+		{name: "subdomain wildcard", origin: "https://pr-12.preview.example.com", method: "GET", allowed: true},
+		{name: "wildcard needs subdomain", origin: "https://preview.example.com", method: "GET"},
+		{name: "unknown origin", origin: "https://evil.example.net", method: "POST"},
+		{name: "method not allowed", origin: "https://app.example.com", method: "PUT"},
+		{name: "header not allowed", origin: "https://app.example.com", method: "POST", headers: "X-Debug"},
+	}
+
+	handler := newTestCORS(t, true)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/reels", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusNoContent {
+				t.Fatalf("Expected preflight to be answered with %d, got %d", http.StatusNoContent, rec.Code)
+			}
+			gotOrigin := rec.Header().Get("Access-Control-Allow-Origin")
+			if !tt.allowed {
+				if gotOrigin != "" {
+					t.Errorf("Expected no Access-Control-Allow-Origin, got %q", gotOrigin)
+				}
+				return
+			}
+			if gotOrigin != tt.origin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.origin, gotOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+				t.Errorf("Unexpected Access-Control-Allow-Methods %q", got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Authorization, Content-Type" {
+				t.Errorf("Unexpected Access-Control-Allow-Headers %q", got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("Expected credentials to be allowed, got %q", got)
+			}
+			if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("Expected Access-Control-Max-Age 600, got %q", got)
+			}
+		})
+	}
+}
+
+func TestMiddleware_ActualRequest(t *testing.T) {
+	handler := newTestCORS(t, false)
+
+	req := httptest.NewRequest(http.MethodPost, "/reels", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected request to reach the handler, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Unexpected Access-Control-Allow-Origin %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID" {
+		t.Errorf("Unexpected Access-Control-Expose-Headers %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no credentials header, got %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Expected Vary: Origin, got %q", got)
+	}
+
+	// An OPTIONS request that is not a preflight is passed through
+	req = httptest.NewRequest(http.MethodOptions, "/reels", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("Expected plain OPTIONS to reach the handler, got %d", rec.Code)
+	}
+}
+
+func TestNew_RejectsCredentialsWithAnyOrigin(t *testing.T) {
+	if _, err := New(Config{AllowedOrigins: []string{"*"}, AllowCredentials: true}); err == nil {
+		t.Error("Expected an error for credentials with origin *")
+	}
+}
+
+func TestMiddleware_DisabledWithoutOrigins(t *testing.T) {
+	c, _ := New(Config{})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest(http.MethodOptions, "/reels", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	c.Middleware(next).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected CORS to be disabled, got %q", got)
+	}
+}
@@ -0,0 +1,316 @@
+// Package dynamotest provides an in-memory fake of the DynamoDB operations
+// used by the gateway's stores, for tests.
+package dynamotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+)
+
+// Item is a DynamoDB item.
+type Item = map[string]types.AttributeValue
+
+// Fake implements dynamo.API over a map. It understands the expressions the
+// gateway's stores write: conditions made of attribute_exists,
+// attribute_not_exists and comparisons joined by AND and OR, "SET a = :v, ..."
+// and "ADD a :v" updates (alone or SET then ADD), and "pk = :v" key conditions.
+type Fake struct {
+	mu    sync.Mutex
+	items map[[2]string]Item
+	// Err, when set, fails every call.
+	Err error
+}
+
+var _ dynamo.API = (*Fake)(nil)
+
+// New creates an empty fake table.
+func New() *Fake {
+	return &Fake{items: make(map[[2]string]Item)}
+}
+
+// Len returns the number of items stored.
+func (f *Fake) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.items)
+}
+
+func itemKey(key Item) [2]string {
+	return [2]string{dynamo.String(key, dynamo.AttrPK), dynamo.String(key, dynamo.AttrSK)}
+}
+
+func (f *Fake) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return &dynamodb.GetItemOutput{Item: maps.Clone(f.items[itemKey(params.Key)])}, nil
+}
+
+func (f *Fake) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	key := itemKey(params.Item)
+	ok, err := evaluate(aws.ToString(params.ConditionExpression), f.items[key], params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	f.items[key] = maps.Clone(params.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *Fake) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	key := itemKey(params.Key)
+	ok, err := evaluate(aws.ToString(params.ConditionExpression), f.items[key], params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	delete(f.items, key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (f *Fake) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	key := itemKey(params.Key)
+	current := f.items[key]
+	ok, err := evaluate(aws.ToString(params.ConditionExpression), current, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	updated := maps.Clone(current)
+	if updated == nil {
+		updated = maps.Clone(params.Key)
+	}
+	if err := update(aws.ToString(params.UpdateExpression), updated, params.ExpressionAttributeNames, params.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	f.items[key] = updated
+	out := &dynamodb.UpdateItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllNew {
+		out.Attributes = maps.Clone(updated)
+	}
+	return out, nil
+}
+
+func (f *Fake) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	name, value, ok := strings.Cut(aws.ToString(params.KeyConditionExpression), " = ")
+	if !ok || resolveName(name, params.ExpressionAttributeNames) != dynamo.AttrPK {
+		return nil, fmt.Errorf("dynamotest: unsupported key condition %q", aws.ToString(params.KeyConditionExpression))
+	}
+	pk := dynamo.String(Item{"v": params.ExpressionAttributeValues[value]}, "v")
+	out := &dynamodb.QueryOutput{}
+	for key, item := range f.items {
+		if key[0] == pk {
+			out.Items = append(out.Items, maps.Clone(item))
+		}
+	}
+	slices.SortFunc(out.Items, func(a, b Item) int {
+		return strings.Compare(dynamo.String(a, dynamo.AttrSK), dynamo.String(b, dynamo.AttrSK))
+	})
+	out.Count = int32(len(out.Items))
+	return out, nil
+}
+
+func (f *Fake) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	reasons := make([]types.CancellationReason, len(params.TransactItems))
+	failed := false
+	for i, op := range params.TransactItems {
+		var ok bool
+		var err error
+		switch {
+		case op.Put != nil:
+			ok, err = evaluate(aws.ToString(op.Put.ConditionExpression), f.items[itemKey(op.Put.Item)], op.Put.ExpressionAttributeNames, op.Put.ExpressionAttributeValues)
+		case op.Delete != nil:
+			ok, err = evaluate(aws.ToString(op.Delete.ConditionExpression), f.items[itemKey(op.Delete.Key)], op.Delete.ExpressionAttributeNames, op.Delete.ExpressionAttributeValues)
+		default:
+			return nil, errors.New("dynamotest: only Put and Delete are supported in transactions")
+		}
+		if err != nil {
+			return nil, err
+		}
+		reasons[i].Code = aws.String("None")
+		if !ok {
+			reasons[i].Code = aws.String("ConditionalCheckFailed")
+			failed = true
+		}
+	}
+	if failed {
+		return nil, &types.TransactionCanceledException{Message: aws.String("Transaction cancelled"), CancellationReasons: reasons}
+	}
+	for _, op := range params.TransactItems {
+		if op.Put != nil {
+			f.items[itemKey(op.Put.Item)] = maps.Clone(op.Put.Item)
+		} else {
+			delete(f.items, itemKey(op.Delete.Key))
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func resolveName(name string, names map[string]string) string {
+	name = strings.TrimSpace(name)
+	if alias, ok := names[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// evaluate reports whether item satisfies condition; an empty condition always holds.
+func evaluate(condition string, item Item, names map[string]string, values Item) (bool, error) {
+	if condition == "" {
+		return true, nil
+	}
+	for _, clause := range strings.Split(condition, " OR ") {
+		holds := true
+		for _, term := range strings.Split(clause, " AND ") {
+			ok, err := evaluateTerm(strings.Trim(term, " ()"), item, names, values)
+			if err != nil {
+				return false, err
+			}
+			holds = holds && ok
+		}
+		if holds {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func evaluateTerm(term string, item Item, names map[string]string, values Item) (bool, error) {
+	if name, ok := strings.CutPrefix(term, "attribute_not_exists("); ok {
+		_, exists := item[resolveName(name, names)]
+		return !exists, nil
+	}
+	if name, ok := strings.CutPrefix(term, "attribute_exists("); ok {
+		_, exists := item[resolveName(name, names)]
+		return exists, nil
+	}
+	fields := strings.Fields(term)
+	if len(fields) != 3 {
+		return false, fmt.Errorf("dynamotest: unsupported condition %q", term)
+	}
+	actual, ok := item[resolveName(fields[0], names)]
+	if !ok {
+		return false, nil
+	}
+	cmp, err := compare(actual, values[fields[2]])
+	if err != nil {
+		return false, err
+	}
+	switch fields[1] {
+	case "=":
+		return cmp == 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	case "<>":
+		return cmp != 0, nil
+	}
+	return false, fmt.Errorf("dynamotest: unsupported operator %q", fields[1])
+}
+
+func compare(a, b types.AttributeValue) (int, error) {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberN:
+		b, ok := b.(*types.AttributeValueMemberN)
+		if !ok {
+			return 0, errors.New("dynamotest: comparing a number with a non-number")
+		}
+		x, _ := strconv.ParseFloat(a.Value, 64)
+		y, _ := strconv.ParseFloat(b.Value, 64)
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	case *types.AttributeValueMemberS:
+		b, ok := b.(*types.AttributeValueMemberS)
+		if !ok {
+			return 0, errors.New("dynamotest: comparing a string with a non-string")
+		}
+		return strings.Compare(a.Value, b.Value), nil
+	}
+	return 0, fmt.Errorf("dynamotest: unsupported comparison of %T", a)
+}
+
+// update applies a "SET a = :v, b = :w" expression, an "ADD a :v"
+// expression, or a SET followed by an ADD, to item.
+func update(expression string, item Item, names map[string]string, values Item) error {
+	if set, add, ok := strings.Cut(expression, " ADD "); ok && strings.HasPrefix(set, "SET ") {
+		if err := update(set, item, names, values); err != nil {
+			return err
+		}
+		return update("ADD "+add, item, names, values)
+	}
+	if rest, ok := strings.CutPrefix(expression, "ADD "); ok {
+		fields := strings.Fields(rest)
+		if len(fields) != 2 {
+			return fmt.Errorf("dynamotest: unsupported update %q", expression)
+		}
+		name := resolveName(fields[0], names)
+		delta := dynamo.Int(Item{"v": values[fields[1]]}, "v")
+		item[name] = dynamo.N(dynamo.Int(item, name) + delta)
+		return nil
+	}
+	if rest, ok := strings.CutPrefix(expression, "SET "); ok {
+		for _, assignment := range strings.Split(rest, ",") {
+			name, value, ok := strings.Cut(assignment, "=")
+			if !ok {
+				return fmt.Errorf("dynamotest: unsupported update %q", expression)
+			}
+			item[resolveName(name, names)] = values[strings.TrimSpace(value)]
+		}
+		return nil
+	}
+	return fmt.Errorf("dynamotest: unsupported update %q", expression)
+}
@@ -0,0 +1,156 @@
+// Package dynamo holds the DynamoDB table the gateway shares between tasks
+// for state that must outlive one process: API keys, runs, HMAC nonces and
+// quotas. Every kind of item lives in one table keyed by pk and sk.
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attribute names shared by every item. The table's partition key is pk and
+// its sort key sk, both strings; DynamoDB TTL should be enabled on expiresAt.
+const (
+	AttrPK        = "pk"
+	AttrSK        = "sk"
+	AttrExpiresAt = "expiresAt"
+)
+
+// API defines the DynamoDB operations used (for testing).
+type API interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// Table is the gateway's DynamoDB table.
+type Table struct {
+	Client API
+	Name   string
+}
+
+// NewTable creates a Table for the table called name.
+func NewTable(client API, name string) *Table {
+	return &Table{Client: client, Name: name}
+}
+
+// TableName returns the table name for request inputs.
+func (t *Table) TableName() *string {
+	return aws.String(t.Name)
+}
+
+// Key returns the primary key of the item at pk and sk.
+func Key(pk, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{AttrPK: S(pk), AttrSK: S(sk)}
+}
+
+// Get reads the item at pk and sk with a strongly consistent read, returning
+// nil when it does not exist or has expired.
+func (t *Table) Get(ctx context.Context, pk, sk string) (map[string]types.AttributeValue, error) {
+	out, err := t.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      t.TableName(),
+		Key:            Key(pk, sk),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil || Expired(out.Item, time.Now()) {
+		return nil, nil
+	}
+	return out.Item, nil
+}
+
+// Ping reads a key that never exists to check the table is reachable.
+func (t *Table) Ping(ctx context.Context) error {
+	_, err := t.Get(ctx, "PING", "PING")
+	return err
+}
+
+// S returns a string attribute.
+func S(v string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: v}
+}
+
+// N returns a number attribute.
+func N(v int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(v, 10)}
+}
+
+// Time returns t as an RFC 3339 string attribute.
+func Time(t time.Time) types.AttributeValue {
+	return S(t.UTC().Format(time.RFC3339Nano))
+}
+
+// ExpiresAt returns t as an expiresAt attribute, in epoch seconds as
+// DynamoDB TTL requires.
+func ExpiresAt(t time.Time) types.AttributeValue {
+	return N(t.Unix())
+}
+
+// String reads a string attribute, or "" when absent.
+func String(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+// Int reads a number attribute, or 0 when absent.
+func Int(item map[string]types.AttributeValue, name string) int64 {
+	if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+		n, _ := strconv.ParseInt(v.Value, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// TimeValue reads a time written with Time, or the zero time when absent.
+func TimeValue(item map[string]types.AttributeValue, name string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, String(item, name))
+	return t
+}
+
+// Expired reports whether item's expiresAt has passed. DynamoDB deletes
+// expired items lazily, so reads must check it themselves.
+func Expired(item map[string]types.AttributeValue, now time.Time) bool {
+	expiresAt := Int(item, AttrExpiresAt)
+	return expiresAt != 0 && now.Unix() >= expiresAt
+}
+
+// IsConditionFailed reports whether err is a failed condition expression,
+// alone or within a cancelled transaction.
+func IsConditionFailed(err error) bool {
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return true
+	}
+	var cancelled *types.TransactionCanceledException
+	if errors.As(err, &cancelled) {
+		for _, reason := range cancelled.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TransactionConditionFailed reports whether err cancelled a transaction
+// because the condition of its index-th item failed.
+func TransactionConditionFailed(err error, index int) bool {
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) || index >= len(cancelled.CancellationReasons) {
+		return false
+	}
+	return aws.ToString(cancelled.CancellationReasons[index].Code) == "ConditionalCheckFailed"
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+	"github.com/wolfman30/api-gateway-go/internal/auth"
+	"github.com/wolfman30/api-gateway-go/internal/httpx"
+	"github.com/wolfman30/api-gateway-go/internal/logging"
+	"github.com/wolfman30/api-gateway-go/internal/models"
+)
+
+var keyManager *auth.KeyManager
+
+// SetKeyManager injects the API key manager used by the admin key endpoints.
+func SetKeyManager(km *auth.KeyManager) {
+	keyManager = km
+}
+
+// keyManagerConfigured replies 503 when key management is unavailable.
+func keyManagerConfigured(w http.ResponseWriter, r *http.Request) bool {
+	if keyManager == nil {
+		apierror.Write(w, r, apierror.New(apierror.CodeNotConfigured, "API key management is not configured"))
+		return false
+	}
+	return true
+}
+
+// ListAPIKeys handles GET /admin/keys
+func ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !keyManagerConfigured(w, r) {
+		return
+	}
+
+	keys, err := keyManager.List(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list API keys", logging.KeyError, err)
+		apierror.Write(w, r, apierror.Wrap(err, apierror.CodeStoreUnavailable, "Failed to list API keys"))
+		return
+	}
+	resp := models.ListAPIKeysResponse{Keys: make([]models.APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, apiKeyResponse(key))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CreateAPIKey handles POST /admin/keys
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !keyManagerConfigured(w, r) {
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := httpx.DecodeJSON(w, r, &req, decodeOptions); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		apierror.Write(w, r, apierror.New(apierror.CodeValidationFailed, "missing required fields: name").
+			WithDetails(map[string][]string{"fields": {"name"}}))
+		return
+	}
+
+	plaintext, key, err := keyManager.Issue(r.Context(), req.Name, req.Scopes, req.ExpiresAt)
+	var invalidScopes *auth.InvalidScopesError
+	if errors.As(err, &invalidScopes) {
+		apierror.Write(w, r, apierror.New(apierror.CodeValidationFailed, invalidScopes.Error()).
+			WithDetails(map[string][]string{"fields": {"scopes"}}))
+		return
+	}
+	if err != nil {
+		writeKeyError(w, r, logging.FromContext(r.Context()), err)
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Issued API key", "keyId", key.ID, "name", key.Name, "scopes", key.Scopes)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.IssuedAPIKeyResponse{Key: plaintext, APIKey: apiKeyResponse(key)})
+}
+
+// RevokeAPIKey handles DELETE /admin/keys/{id}
+func RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !keyManagerConfigured(w, r) {
+		return
+	}
+
+	id := r.PathValue("id")
+	logger := logging.FromContext(r.Context()).With("keyId", id)
+	if err := keyManager.Revoke(r.Context(), id); err != nil {
+		writeKeyError(w, r, logger, err)
+		return
+	}
+	logger.Info("Revoked API key")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateAPIKey handles POST /admin/keys/{id}/rotate
+func RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !keyManagerConfigured(w, r) {
+		return
+	}
+
+	id := r.PathValue("id")
+	logger := logging.FromContext(r.Context()).With("keyId", id)
+	plaintext, key, err := keyManager.Rotate(r.Context(), id)
+	if err != nil {
+		writeKeyError(w, r, logger, err)
+		return
+	}
+	logger.Info("Rotated API key")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.IssuedAPIKeyResponse{Key: plaintext, APIKey: apiKeyResponse(key)})
+}
+
+func writeKeyError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		apierror.Write(w, r, apierror.New(apierror.CodeAPIKeyNotFound, "API key not found"))
+	case errors.Is(err, auth.ErrKeyRevoked):
+		apierror.Write(w, r, apierror.New(apierror.CodeAPIKeyRevoked, "API key is revoked"))
+	default:
+		logger.Error("API key operation failed", logging.KeyError, err)
+		apierror.Write(w, r, apierror.Wrap(err, apierror.CodeStoreUnavailable, "API key operation failed"))
+	}
+}
+
+func apiKeyResponse(key *auth.APIKey) models.APIKeyResponse {
+	return models.APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		ExpiresAt: key.ExpiresAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
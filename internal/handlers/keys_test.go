@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wolfman30/api-gateway-go/internal/auth"
+	"github.com/wolfman30/api-gateway-go/internal/models"
+)
+
+func TestAPIKeys_Lifecycle(t *testing.T) {
+	km := auth.NewKeyManager(auth.NewMemoryKeyStore())
+	SetKeyManager(km)
+	defer SetKeyManager(nil)
+
+	// Create
+	body, _ := json.Marshal(models.CreateAPIKeyRequest{Name: "partner", Scopes: []string{auth.ScopeReelsWrite}})
+	rec := httptest.NewRecorder()
+	serve(rec, newJSONRequest(http.MethodPost, "/admin/keys", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var created models.IssuedAPIKeyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.Key == "" || created.APIKey.ID == "" {
+		t.Fatalf("Expected plaintext key and ID, got %+v", created)
+	}
+
+	// List never exposes the plaintext
+	rec = httptest.NewRecorder()
+	serve(rec, httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+	if bytes.Contains(rec.Body.Bytes(), []byte(created.Key)) {
+		t.Error("Expected list response not to contain the plaintext key")
+	}
+	var list models.ListAPIKeysResponse
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list.Keys) != 1 || list.Keys[0].ID != created.APIKey.ID {
+		t.Fatalf("Expected one listed key, got %+v", list)
+	}
+
+	// Rotate
+	rec = httptest.NewRecorder()
+	serve(rec, httptest.NewRequest(http.MethodPost, "/admin/keys/"+created.APIKey.ID+"/rotate", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d rotating, got %d", http.StatusOK, rec.Code)
+	}
+	var rotated models.IssuedAPIKeyResponse
+	json.NewDecoder(rec.Body).Decode(&rotated)
+	if rotated.Key == created.Key {
+		t.Error("Expected a new plaintext key after rotation")
+	}
+
+	// Revoke
+	rec = httptest.NewRecorder()
+	serve(rec, httptest.NewRequest(http.MethodDelete, "/admin/keys/"+created.APIKey.ID, nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d revoking, got %d", http.StatusNoContent, rec.Code)
+	}
+	if _, err := km.Verify(context.Background(), rotated.Key); err == nil {
+		t.Error("Expected revoked key to fail verification")
+	}
+}
+
+func TestAPIKeys_Errors(t *testing.T) {
+	SetKeyManager(auth.NewKeyManager(auth.NewMemoryKeyStore()))
+	defer SetKeyManager(nil)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "unknown scope", method: http.MethodPost, target: "/admin/keys", body: `{"name":"x","scopes":["nope"]}`, status: http.StatusBadRequest, code: "validation_failed"},
+		{name: "missing scopes", method: http.MethodPost, target: "/admin/keys", body: `{"name":"x"}`, status: http.StatusBadRequest, code: "validation_failed"},
+		{name: "missing name", method: http.MethodPost, target: "/admin/keys", body: `{"scopes":["runs:read"]}`, status: http.StatusBadRequest, code: "validation_failed"},
+		{name: "revoke unknown", method: http.MethodDelete, target: "/admin/keys/missing", status: http.StatusNotFound, code: "api_key_not_found"},
+		{name: "rotate wrong method", method: http.MethodGet, target: "/admin/keys/abc/rotate", status: http.StatusMethodNotAllowed},
+		{name: "unknown action", method: http.MethodPost, target: "/admin/keys/abc/disable", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			serve(rec, newJSONRequest(tt.method, tt.target, bytes.NewReader([]byte(tt.body))))
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.code != "" {
+				var resp models.ErrorResponse
+				json.NewDecoder(rec.Body).Decode(&resp)
+				if resp.Code != tt.code {
+					t.Errorf("Expected code %s, got %+v", tt.code, resp)
+				}
+			}
+		})
+	}
+}
+
+// failingKeyStore fails every write the way an unreachable table would.
+type failingKeyStore struct {
+	*auth.MemoryKeyStore
+}
+
+func (failingKeyStore) Put(ctx context.Context, key *auth.APIKey) error {
+	return errors.New("operation error DynamoDB: TransactWriteItems, https response error StatusCode: 500")
+}
+
+func TestCreateAPIKey_StoreFailure(t *testing.T) {
+	SetKeyManager(auth.NewKeyManager(failingKeyStore{auth.NewMemoryKeyStore()}))
+	defer SetKeyManager(nil)
+
+	rec := httptest.NewRecorder()
+	serve(rec, newJSONRequest(http.MethodPost, "/admin/keys", bytes.NewReader([]byte(`{"name":"x","scopes":["runs:read"]}`))))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusServiceUnavailable, rec.Code, rec.Body.String())
+	}
+	var resp models.ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Code != "store_unavailable" || strings.Contains(rec.Body.String(), "DynamoDB") {
+		t.Errorf("Expected store_unavailable without the store's error text, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/auth"
+	"github.com/wolfman30/api-gateway-go/internal/httpx"
+	"github.com/wolfman30/api-gateway-go/internal/logging"
+	"github.com/wolfman30/api-gateway-go/internal/models"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+)
+
+var clientCredentials *auth.ClientCredentials
+
+// SetClientCredentials injects the OAuth2 client-credentials grant for the token endpoint.
+func SetClientCredentials(cc *auth.ClientCredentials) {
+	clientCredentials = cc
+}
+
+// OAuthToken handles POST /oauth/token (client-credentials grant only).
+// Clients authenticate with HTTP Basic or client_id/client_secret form fields.
+func OAuthToken(w http.ResponseWriter, r *http.Request) {
+	if clientCredentials == nil {
+		writeOAuthError(w, r, http.StatusServiceUnavailable, "temporarily_unavailable", "token issuance is not configured")
+		return
+	}
+
+	maxBytes := decodeOptions.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = httpx.DefaultMaxBodyBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	if err := r.ParseForm(); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeOAuthError(w, r, http.StatusRequestEntityTooLarge, "invalid_request", fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		writeOAuthError(w, r, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+	if grant := r.PostForm.Get("grant_type"); grant != "client_credentials" {
+		writeOAuthError(w, r, http.StatusBadRequest, "unsupported_grant_type", "only client_credentials is supported")
+		return
+	}
+
+	clientID, clientSecret, basic := r.BasicAuth()
+	if !basic {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	logger := logging.FromContext(r.Context()).With("clientId", clientID)
+	token, expiresAt, scopes, err := clientCredentials.Token(clientID, clientSecret, r.PostForm.Get("scope"))
+	switch {
+	case errors.Is(err, auth.ErrInvalidClient):
+		logger.Warn("Rejected token request")
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="api-gateway"`)
+		}
+		writeOAuthError(w, r, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	case errors.Is(err, auth.ErrInvalidScope):
+		writeOAuthError(w, r, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	case err != nil:
+		logger.Error("Failed to issue token", logging.KeyError, err)
+		writeOAuthError(w, r, http.StatusInternalServerError, "server_error", "failed to issue token")
+		return
+	}
+
+	logger.Info("Issued access token", "scopes", scopes)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	json.NewEncoder(w).Encode(models.TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Round(time.Second).Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.OAuthErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+		RequestID:        requestid.FromContext(r.Context()),
+	})
+}
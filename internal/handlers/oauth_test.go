@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/auth"
+	"github.com/wolfman30/api-gateway-go/internal/httpx"
+	"github.com/wolfman30/api-gateway-go/internal/models"
+)
+
+func TestOAuthToken(t *testing.T) {
+	issuer := auth.NewTokenIssuer([]byte("test-secret"), "api-gateway-dev", 5*time.Minute)
+	SetClientCredentials(auth.NewClientCredentials(issuer, auth.OAuthClient{
+		ID: "client-a", Secret: "s3cret", Scopes: []string{auth.ScopeReelsWrite, auth.ScopeRunsRead},
+	}))
+	defer SetClientCredentials(nil)
+
+	tests := []struct {
+		name      string
+		form      url.Values
+		basicUser string
+		basicPass string
+		status    int
+		errCode   string
+	}{
+		{name: "basic auth", form: url.Values{"grant_type": {"client_credentials"}}, basicUser: "client-a", basicPass: "s3cret", status: http.StatusOK},
+		{name: "form credentials", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"client-a"}, "client_secret": {"s3cret"}, "scope": {"runs:read"}}, status: http.StatusOK},
+		{name: "wrong secret", form: url.Values{"grant_type": {"client_credentials"}}, basicUser: "client-a", basicPass: "nope", status: http.StatusUnauthorized, errCode: "invalid_client"},
+		{name: "unsupported grant", form: url.Values{"grant_type": {"password"}}, basicUser: "client-a", basicPass: "s3cret", status: http.StatusBadRequest, errCode: "unsupported_grant_type"},
+		{name: "scope not granted", form: url.Values{"grant_type": {"client_credentials"}, "scope": {"keys:admin"}}, basicUser: "client-a", basicPass: "s3cret", status: http.StatusBadRequest, errCode: "invalid_scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.basicUser != "" {
+				req.SetBasicAuth(tt.basicUser, tt.basicPass)
+			}
+			rec := httptest.NewRecorder()
+
+			serve(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if rec.Header().Get("Cache-Control") != "no-store" {
+				t.Error("Expected Cache-Control: no-store")
+			}
+
+			if tt.errCode != "" {
+				var resp models.OAuthErrorResponse
+				json.NewDecoder(rec.Body).Decode(&resp)
+				if resp.Error != tt.errCode {
+					t.Errorf("Expected error %s, got %s", tt.errCode, resp.Error)
+				}
+				return
+			}
+
+			var resp models.TokenResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.TokenType != "Bearer" || resp.ExpiresIn <= 0 || resp.ExpiresIn > 300 {
+				t.Errorf("Unexpected token response %+v", resp)
+			}
+			if _, err := issuer.Verify(resp.AccessToken); err != nil {
+				t.Errorf("Expected issued token to verify, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOAuthToken_NotConfigured(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader("grant_type=client_credentials"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	serve(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+func TestOAuthToken_BodyLimit(t *testing.T) {
+	issuer := auth.NewTokenIssuer([]byte("test-secret"), "api-gateway-dev", 5*time.Minute)
+	SetClientCredentials(auth.NewClientCredentials(issuer, auth.OAuthClient{ID: "client-a", Secret: "s3cret"}))
+	SetDecodeOptions(httpx.DecodeOptions{MaxBytes: 64, Strict: true})
+	defer func() {
+		SetClientCredentials(nil)
+		SetDecodeOptions(httpx.DecodeOptions{Strict: true})
+	}()
+
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {strings.Repeat("a", 64)}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("client-a", "s3cret")
+	rec := httptest.NewRecorder()
+
+	serve(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+	}
+	var resp models.OAuthErrorResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Error != "invalid_request" || !strings.Contains(resp.ErrorDescription, "64 bytes") {
+		t.Errorf("Unexpected error response %+v", resp)
+	}
+}
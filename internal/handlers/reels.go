@@ -2,48 +2,189 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/google/uuid"
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+	"github.com/wolfman30/api-gateway-go/internal/auth"
 	"github.com/wolfman30/api-gateway-go/internal/bus"
+	"github.com/wolfman30/api-gateway-go/internal/httpx"
+	"github.com/wolfman30/api-gateway-go/internal/logging"
+	"github.com/wolfman30/api-gateway-go/internal/metrics"
 	"github.com/wolfman30/api-gateway-go/internal/models"
+	"github.com/wolfman30/api-gateway-go/internal/quota"
+	"github.com/wolfman30/api-gateway-go/internal/store"
 )
 
-var publisher *bus.Publisher
+var (
+	publisher      *bus.Publisher
+	runStore       store.RunStore
+	quotas         *quota.Limiter
+	gatewayMetrics *metrics.Metrics
+	decodeOptions  = httpx.DecodeOptions{Strict: true}
+)
 
 // SetPublisher injects the SQS publisher for handlers to use.
 func SetPublisher(p *bus.Publisher) {
 	publisher = p
 }
 
+// SetMetrics injects the metrics used to count accepted runs.
+func SetMetrics(m *metrics.Metrics) {
+	gatewayMetrics = m
+}
+
+// SetDecodeOptions sets the body size limit and strictness used to decode JSON requests.
+func SetDecodeOptions(opts httpx.DecodeOptions) {
+	decodeOptions = opts
+}
+
+// SetRunStore injects the run store used to record and look up runs.
+func SetRunStore(s store.RunStore) {
+	runStore = s
+}
+
+// SetQuota injects the per-project reel quota; nil disables it.
+func SetQuota(l *quota.Limiter) {
+	quotas = l
+}
+
+// authorizeProject reports whether the authenticated caller may act on
+// projectID. Requests without a principal are denied, so a route wired
+// without auth.Require fails closed.
+func authorizeProject(r *http.Request, projectID string) bool {
+	p, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		logging.FromContext(r.Context()).Error("Project access denied: request has no principal", logging.KeyProjectID, projectID)
+		return false
+	}
+	if p.CanAccessProject(projectID) {
+		return true
+	}
+	logging.FromContext(r.Context()).Warn("Project access denied", logging.KeyProjectID, projectID)
+	return false
+}
+
+// projectForbidden is returned when the caller may not act on projectID.
+func projectForbidden(projectID string) *apierror.Error {
+	return apierror.New(apierror.CodeForbidden, "Not authorized for project").
+		WithDetails(map[string]string{"projectId": projectID})
+}
+
+// validationError lists the fields that failed validation in the error details.
+func validationError(err error) *apierror.Error {
+	e := apierror.New(apierror.CodeValidationFailed, err.Error())
+	var ve *models.ValidationError
+	if errors.As(err, &ve) {
+		e.WithDetails(map[string][]string{"fields": ve.Fields})
+	}
+	return e
+}
+
 // CreateReel handles POST /reels
 func CreateReel(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	dryRun, viaPrefer, err := isDryRun(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.New(apierror.CodeInvalidRequest, "Invalid dryRun parameter"))
 		return
 	}
 
 	var req models.CreateReelRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Decode error: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := httpx.DecodeJSON(w, r, &req, decodeOptions); err != nil {
+		logging.FromContext(r.Context()).Info("Invalid request body", logging.KeyError, err)
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		apierror.Write(w, r, validationError(err))
+		return
+	}
+
+	if !authorizeProject(r, req.ProjectID) {
+		apierror.Write(w, r, projectForbidden(req.ProjectID))
+		return
+	}
+
+	// Without a queue there is nothing to accept the reel; dry runs report
+	// the same so they never succeed where the real request would fail
+	if publisher == nil {
+		apierror.Write(w, r, apierror.New(apierror.CodeNotConfigured, "Reel publisher is not configured"))
+		return
+	}
+
+	// Dry runs are held to the same quota, but do not use it
+	if !reserveQuota(w, r, req.ProjectID, dryRun) {
 		return
 	}
 
 	// Generate a unique run ID
 	runID := uuid.New().String()
+	r = r.WithContext(logging.With(r.Context(), logging.KeyRunID, runID, logging.KeyProjectID, req.ProjectID))
+	logger := logging.FromContext(r.Context())
+
+	if dryRun {
+		input, err := bus.BuildReelCommand(r.Context(), publisher.QueueURL(), runID, req)
+		if err != nil {
+			logger.Error("Failed to build reel command", logging.KeyError, err)
+			apierror.Write(w, r, apierror.Wrap(err, apierror.CodeInternal, "Failed to build reel command"))
+			return
+		}
+
+		logger.Info("Dry run accepted, command not published")
+
+		if viaPrefer {
+			w.Header().Set("Preference-Applied", "dry-run")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.DryRunResponse{
+			DryRun:  true,
+			RunID:   runID,
+			Message: reelCommandMessage(input),
+		})
+		return
+	}
+
+	// Record the run before publishing so its project is known once the orchestrator picks it up
+	if runStore != nil {
+		now := time.Now().UTC()
+		run := &store.Run{RunID: runID, ProjectID: req.ProjectID, Status: "PENDING", CreatedAt: now, UpdatedAt: now}
+		if err := runStore.CreateRun(r.Context(), run); err != nil {
+			logger.Error("Failed to record run", logging.KeyError, err)
+			releaseQuota(r, req.ProjectID)
+			apierror.Write(w, r, apierror.Wrap(err, apierror.CodeStoreUnavailable, "Failed to record run"))
+			return
+		}
+	}
 
 	// Publish command to SQS for orchestrator pickup
-	if publisher != nil {
-		if err := publisher.PublishReelCommand(runID, req); err != nil {
-			log.Printf("Failed to publish command: %v", err)
-			http.Error(w, "Failed to enqueue reel command", http.StatusInternalServerError)
+	if err := publisher.PublishReelCommand(r.Context(), runID, req); err != nil {
+		logger.Error("Failed to publish reel command", logging.KeyError, err)
+		if runStore != nil {
+			if err := runStore.DeleteRun(r.Context(), runID); err != nil {
+				logger.Error("Failed to remove unpublished run", logging.KeyError, err)
+			}
+		}
+		releaseQuota(r, req.ProjectID)
+		if errors.Is(err, bus.ErrPublisherClosed) {
+			apierror.Write(w, r, apierror.Wrap(err, apierror.CodeShuttingDown, "Gateway is shutting down"))
 			return
 		}
+		apierror.Write(w, r, apierror.Wrap(err, apierror.CodeEnqueueFailed, "Failed to enqueue reel command"))
+		return
 	}
 
-	log.Printf("Accepted reel request for project %s, runID=%s", req.ProjectID, runID)
+	logger.Info("Accepted reel request")
+	if gatewayMetrics != nil {
+		gatewayMetrics.RunAccepted(req.ProjectID)
+	}
 
 	// Return 202 Accepted with runID
 	w.Header().Set("Content-Type", "application/json")
@@ -51,30 +192,114 @@ func CreateReel(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(models.CreateReelResponse{RunID: runID})
 }
 
-// GetRunStatus handles GET /runs/{runId}
-func GetRunStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// reserveQuota checks the project's reel quota and, unless this is a dry
+// run, uses one reel of it. When the request may not proceed it writes the
+// error response and returns false.
+func reserveQuota(w http.ResponseWriter, r *http.Request, projectID string, dryRun bool) bool {
+	if quotas == nil {
+		return true
+	}
+	check := quotas.Consume
+	if dryRun {
+		check = quotas.Check
 	}
 
-	// Extract runId from path
-	runID := r.URL.Path[len("/runs/"):]
-	if runID == "" {
-		http.Error(w, "Missing runId", http.StatusBadRequest)
+	decision, err := check(r.Context(), projectID)
+	if errors.Is(err, quota.ErrExceeded) {
+		logging.FromContext(r.Context()).Info("Reel quota exceeded", logging.KeyProjectID, projectID)
+		w.Header().Set("Retry-After", strconv.Itoa(int(max(time.Until(decision.ResetAt).Round(time.Second).Seconds(), 1))))
+		apierror.Write(w, r, apierror.New(apierror.CodeQuotaExceeded, "Reel quota exceeded for project").
+			WithDetails(map[string]any{"projectId": projectID, "limit": decision.Limit, "resetAt": decision.ResetAt}))
+		return false
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to check quota", logging.KeyError, err)
+		apierror.Write(w, r, apierror.Wrap(err, apierror.CodeStoreUnavailable, "Failed to check quota"))
+		return false
+	}
+	return true
+}
+
+// releaseQuota gives back the reel reserved for a request that was not accepted.
+func releaseQuota(r *http.Request, projectID string) {
+	if quotas == nil {
 		return
 	}
+	if err := quotas.Release(r.Context(), projectID); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to release quota", logging.KeyError, err)
+	}
+}
 
-	// TODO: Query DynamoDB for run state
-	log.Printf("Fetching status for runID=%s", runID)
+// isDryRun reports whether the caller asked for a dry run, either with the
+// dryRun query parameter or a "Prefer: dry-run" header. viaPrefer is set when
+// the header was honoured so the response can carry Preference-Applied.
+func isDryRun(r *http.Request) (dryRun, viaPrefer bool, err error) {
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			return false, false, err
+		}
+	}
 
-	// Stub response
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "dry-run") {
+				return true, true, nil
+			}
+		}
+	}
+
+	return dryRun, false, nil
+}
+
+// reelCommandMessage flattens an SQS message into its JSON representation.
+func reelCommandMessage(input *sqs.SendMessageInput) models.ReelCommandMessage {
+	attrs := make(map[string]string, len(input.MessageAttributes))
+	for name, attr := range input.MessageAttributes {
+		attrs[name] = aws.ToString(attr.StringValue)
+	}
+	return models.ReelCommandMessage{
+		QueueURL:          aws.ToString(input.QueueUrl),
+		MessageBody:       aws.ToString(input.MessageBody),
+		MessageAttributes: attrs,
+	}
+}
+
+// GetRunStatus handles GET /runs/{runId}
+func GetRunStatus(w http.ResponseWriter, r *http.Request) {
+	runID := r.PathValue("runId")
+	r = r.WithContext(logging.With(r.Context(), logging.KeyRunID, runID))
+	logger := logging.FromContext(r.Context())
+	logger.Debug("Fetching run status")
+
+	// Stub response when no run store is configured
 	resp := models.RunStatusResponse{
 		RunID:  runID,
 		Status: "PENDING",
 		Steps:  []models.RunStep{},
 	}
 
+	if runStore != nil {
+		run, err := runStore.GetRun(r.Context(), runID)
+		if err != nil && !errors.Is(err, store.ErrRunNotFound) {
+			logger.Error("Failed to load run", logging.KeyError, err)
+			apierror.Write(w, r, apierror.Wrap(err, apierror.CodeStoreUnavailable, "Failed to load run"))
+			return
+		}
+
+		// A run in a project the caller cannot access is reported exactly like a
+		// missing one, so run IDs cannot be probed across projects
+		if run == nil || !authorizeProject(r, run.ProjectID) {
+			apierror.Write(w, r, apierror.New(apierror.CodeRunNotFound, "Run not found"))
+			return
+		}
+
+		resp.Status = run.Status
+		if run.Steps != nil {
+			resp.Steps = run.Steps
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
@@ -2,15 +2,29 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/wolfman30/api-gateway-go/internal/auth"
+	"github.com/wolfman30/api-gateway-go/internal/bus"
+	"github.com/wolfman30/api-gateway-go/internal/httpx"
 	"github.com/wolfman30/api-gateway-go/internal/models"
+	"github.com/wolfman30/api-gateway-go/internal/quota"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+	"github.com/wolfman30/api-gateway-go/internal/store"
 )
 
 func TestCreateReel(t *testing.T) {
+	SetPublisher(bus.NewPublisher("queue", &fakeSQSClient{}))
+	defer SetPublisher(nil)
+
 	// Sample payload matching the digital marketing ICP example
 	payload := models.CreateReelRequest{
 		ProjectID: "proj_789",
@@ -38,11 +52,11 @@ func TestCreateReel(t *testing.T) {
 		t.Fatalf("Failed to marshal payload: %v", err)
 	}
 
-	req := httptest.NewRequest(http.MethodPost, "/reels", bytes.NewReader(body))
+	req := newJSONRequest(http.MethodPost, "/reels", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 
-	CreateReel(rec, req)
+	serve(rec, req)
 
 	// Assert status code
 	if rec.Code != http.StatusAccepted {
@@ -64,11 +78,11 @@ func TestCreateReel(t *testing.T) {
 }
 
 func TestCreateReel_InvalidPayload(t *testing.T) {
-	req := httptest.NewRequest(http.MethodPost, "/reels", bytes.NewReader([]byte("invalid json")))
+	req := newJSONRequest(http.MethodPost, "/reels", bytes.NewReader([]byte("invalid json")))
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
 
-	CreateReel(rec, req)
+	serve(rec, req)
 
 	if rec.Code != http.StatusBadRequest {
 		t.Errorf("Expected status %d for invalid JSON, got %d", http.StatusBadRequest, rec.Code)
@@ -79,7 +93,7 @@ func TestCreateReel_MethodNotAllowed(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/reels", nil)
 	rec := httptest.NewRecorder()
 
-	CreateReel(rec, req)
+	serve(rec, req)
 
 	if rec.Code != http.StatusMethodNotAllowed {
 		t.Errorf("Expected status %d for wrong method, got %d", http.StatusMethodNotAllowed, rec.Code)
@@ -91,7 +105,7 @@ func TestGetRunStatus(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/runs/"+runID, nil)
 	rec := httptest.NewRecorder()
 
-	GetRunStatus(rec, req)
+	serve(rec, req)
 
 	if rec.Code != http.StatusOK {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
@@ -115,7 +129,7 @@ func TestGetRunStatus_MethodNotAllowed(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/runs/test-run", nil)
 	rec := httptest.NewRecorder()
 
-	GetRunStatus(rec, req)
+	serve(rec, req)
 
 	if rec.Code != http.StatusMethodNotAllowed {
 		t.Errorf("Expected status %d for wrong method, got %d", http.StatusMethodNotAllowed, rec.Code)
@@ -126,9 +140,394 @@ func TestGetRunStatus_MissingRunID(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/runs/", nil)
 	rec := httptest.NewRecorder()
 
-	GetRunStatus(rec, req)
+	serve(rec, req)
+
+	// "/runs/" matches no route, so a missing runId is an unknown path
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for missing runID, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+// fakeSQSClient records every message sent through it.
+type fakeSQSClient struct {
+	sent []*sqs.SendMessageInput
+}
+
+func (f *fakeSQSClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.sent = append(f.sent, params)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func validReelRequest() models.CreateReelRequest {
+	return models.CreateReelRequest{
+		ProjectID: "proj_789",
+		ICP: models.IdealClientProfile{
+			Industry:           "Digital marketing",
+			AudiencePainPoints: []string{"Creating consistent content takes too much time"},
+		},
+		Idea:       "Show how AI twins let you create reels in minutes instead of hours",
+		FluxModel:  models.FluxModelConfig{LoraURL: "https://example.com/lora.safetensors"},
+		FluxPrompt: models.FluxPromptRequest{Prompt: "Professional digital marketer in modern home office setup"},
+	}
+}
+
+func TestCreateReel_DryRun(t *testing.T) {
+	client := &fakeSQSClient{}
+	SetPublisher(bus.NewPublisher("https://sqs.us-east-1.amazonaws.com/123456789012/test-queue", client))
+	defer SetPublisher(nil)
+
+	body, _ := json.Marshal(validReelRequest())
+
+	tests := []struct {
+		name          string
+		target        string
+		prefer        string
+		wantPrefApply bool
+	}{
+		{name: "query parameter", target: "/reels?dryRun=true"},
+		{name: "prefer header", target: "/reels", prefer: "respond-async, dry-run", wantPrefApply: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newJSONRequest(http.MethodPost, tt.target, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.prefer != "" {
+				req.Header.Set("Prefer", tt.prefer)
+			}
+			rec := httptest.NewRecorder()
+
+			serve(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Header().Get("Preference-Applied") == "dry-run"; got != tt.wantPrefApply {
+				t.Errorf("Expected Preference-Applied=%v, got header %q", tt.wantPrefApply, rec.Header().Get("Preference-Applied"))
+			}
+
+			var resp models.DryRunResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !resp.DryRun || resp.RunID == "" {
+				t.Errorf("Expected dry run with runID, got %+v", resp)
+			}
+			if resp.Message.QueueURL != publisher.QueueURL() {
+				t.Errorf("Expected queueUrl %s, got %s", publisher.QueueURL(), resp.Message.QueueURL)
+			}
+			if resp.Message.MessageAttributes["runId"] != resp.RunID {
+				t.Errorf("Expected runId attribute %s, got %s", resp.RunID, resp.Message.MessageAttributes["runId"])
+			}
+
+			var echoed models.CreateReelRequest
+			if err := json.Unmarshal([]byte(resp.Message.MessageBody), &echoed); err != nil {
+				t.Fatalf("Message body is not a reel request: %v", err)
+			}
+			if echoed.ProjectID != "proj_789" {
+				t.Errorf("Expected projectId proj_789 in message body, got %s", echoed.ProjectID)
+			}
+		})
+	}
+
+	if len(client.sent) != 0 {
+		t.Errorf("Expected no messages published in dry run, got %d", len(client.sent))
+	}
+}
+
+func TestCreateReel_InvalidDryRunParam(t *testing.T) {
+	body, _ := json.Marshal(validReelRequest())
+	req := newJSONRequest(http.MethodPost, "/reels?dryRun=maybe", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	serve(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid dryRun, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestCreateReel_MissingRequiredFields(t *testing.T) {
+	payload := validReelRequest()
+	payload.ProjectID = ""
+	payload.FluxPrompt.Prompt = ""
+	body, _ := json.Marshal(payload)
+
+	req := newJSONRequest(http.MethodPost, "/reels?dryRun=true", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	serve(rec, req)
 
 	if rec.Code != http.StatusBadRequest {
-		t.Errorf("Expected status %d for missing runID, got %d", http.StatusBadRequest, rec.Code)
+		t.Fatalf("Expected status %d for missing fields, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "projectId") || !strings.Contains(rec.Body.String(), "fluxPrompt.prompt") {
+		t.Errorf("Expected missing fields in error, got %q", rec.Body.String())
+	}
+}
+
+func TestProjectAuthorization(t *testing.T) {
+	runs := store.NewMemoryRunStore(store.MemoryRunStoreOptions{})
+	SetRunStore(runs)
+	defer SetRunStore(nil)
+	SetPublisher(bus.NewPublisher("queue", &fakeSQSClient{}))
+	defer SetPublisher(nil)
+
+	owner := &auth.Principal{ID: "apikey:owner", Projects: []string{"proj_789"}}
+	stranger := &auth.Principal{ID: "apikey:stranger", Projects: []string{"proj_other"}}
+	admin := &auth.Principal{ID: "apikey:admin", Admin: true}
+
+	withPrincipal := func(req *http.Request, p *auth.Principal) *http.Request {
+		return req.WithContext(auth.WithPrincipal(req.Context(), p))
+	}
+	createReel := func(p *auth.Principal) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(validReelRequest())
+		rec := httptest.NewRecorder()
+		serve(rec, withPrincipal(newJSONRequest(http.MethodPost, "/reels", bytes.NewReader(body)), p))
+		return rec
+	}
+
+	if rec := createReel(stranger); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d creating reel for another project, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	// A route wired without auth.Require fails closed
+	body, _ := json.Marshal(validReelRequest())
+	anonymous := httptest.NewRecorder()
+	newTestRouter().ServeHTTP(anonymous, newJSONRequest(http.MethodPost, "/reels", bytes.NewReader(body)))
+	if anonymous.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d creating reel without a principal, got %d", http.StatusForbidden, anonymous.Code)
+	}
+
+	rec := createReel(owner)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	var created models.CreateReelResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		runID     string
+		status    int
+	}{
+		{name: "owner", principal: owner, runID: created.RunID, status: http.StatusOK},
+		{name: "admin bypass", principal: admin, runID: created.RunID, status: http.StatusOK},
+		{name: "other project", principal: stranger, runID: created.RunID, status: http.StatusNotFound},
+		{name: "unknown run", principal: owner, runID: "00000000-0000-0000-0000-000000000000", status: http.StatusNotFound},
+		{name: "no principal", runID: created.RunID, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/runs/"+tt.runID, nil)
+			if tt.principal != nil {
+				req = withPrincipal(req, tt.principal)
+			}
+			rec := httptest.NewRecorder()
+			// Bypass serve, which would authenticate the request as an admin
+			newTestRouter().ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestCreateReel_DryRunDoesNotPersist(t *testing.T) {
+	runs := store.NewMemoryRunStore(store.MemoryRunStoreOptions{})
+	SetRunStore(runs)
+	defer SetRunStore(nil)
+	SetPublisher(bus.NewPublisher("queue", &fakeSQSClient{}))
+	defer SetPublisher(nil)
+
+	body, _ := json.Marshal(validReelRequest())
+	rec := httptest.NewRecorder()
+	serve(rec, newJSONRequest(http.MethodPost, "/reels?dryRun=true", bytes.NewReader(body)))
+
+	var resp models.DryRunResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if _, err := runs.GetRun(context.Background(), resp.RunID); err == nil {
+		t.Error("Expected dry run not to persist a run")
+	}
+}
+
+func TestCreateReel_RequestIDCorrelation(t *testing.T) {
+	SetPublisher(bus.NewPublisher("queue", &fakeSQSClient{}))
+	defer SetPublisher(nil)
+
+	handler := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		CreateReel(w, withTestPrincipal(r))
+	}))
+
+	// Error bodies quote the caller's request ID
+	req := newJSONRequest(http.MethodPost, "/reels", bytes.NewBufferString("not json"))
+	req.Header.Set(requestid.Header, "req-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if got := rec.Header().Get(requestid.Header); got != "req-123" {
+		t.Errorf("Expected %s header req-123, got %q", requestid.Header, got)
+	}
+	var errResp models.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
+		t.Fatalf("Failed to decode error body: %v", err)
+	}
+	if errResp.RequestID != "req-123" {
+		t.Errorf("Expected requestId req-123 in error body, got %+v", errResp)
+	}
+
+	// The request ID is forwarded as an SQS message attribute
+	body, _ := json.Marshal(validReelRequest())
+	req = newJSONRequest(http.MethodPost, "/reels?dryRun=true", bytes.NewReader(body))
+	req.Header.Set(requestid.Header, "req-456")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var dryRun models.DryRunResponse
+	if err := json.NewDecoder(rec.Body).Decode(&dryRun); err != nil {
+		t.Fatalf("Failed to decode dry run body: %v", err)
+	}
+	if got := dryRun.Message.MessageAttributes["requestId"]; got != "req-456" {
+		t.Errorf("Expected requestId attribute req-456, got %q", got)
+	}
+}
+
+// failingSQSClient rejects every message.
+type failingSQSClient struct{}
+
+func (failingSQSClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	return nil, errors.New("sqs: service unavailable")
+}
+
+func TestCreateReel_ErrorCodes(t *testing.T) {
+	SetRunStore(store.NewMemoryRunStore(store.MemoryRunStoreOptions{}))
+	defer SetRunStore(nil)
+
+	invalid := validReelRequest()
+	invalid.Idea = ""
+
+	tests := []struct {
+		name      string
+		publisher *bus.Publisher
+		payload   any
+		status    int
+		code      string
+		retryable bool
+	}{
+		{name: "validation", payload: invalid, status: http.StatusBadRequest, code: "validation_failed"},
+		{name: "malformed body", payload: "not an object", status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "publisher not configured", payload: validReelRequest(), status: http.StatusServiceUnavailable, code: "not_configured"},
+		{name: "publish failure", publisher: bus.NewPublisher("queue", failingSQSClient{}), payload: validReelRequest(), status: http.StatusServiceUnavailable, code: "enqueue_failed", retryable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPublisher(tt.publisher)
+			defer SetPublisher(nil)
+
+			body, _ := json.Marshal(tt.payload)
+			rec := httptest.NewRecorder()
+			serve(rec, newJSONRequest(http.MethodPost, "/reels", bytes.NewReader(body)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			var resp models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode error body: %v", err)
+			}
+			if resp.Code != tt.code || resp.Retryable != tt.retryable {
+				t.Errorf("Expected code %s retryable=%v, got %+v", tt.code, tt.retryable, resp)
+			}
+		})
+	}
+}
+
+func TestCreateReel_Quota(t *testing.T) {
+	client := &fakeSQSClient{}
+	SetPublisher(bus.NewPublisher("queue", client))
+	SetQuota(quota.NewLimiter(quota.NewMemoryCounter(), 1, time.Hour))
+	defer func() {
+		SetPublisher(nil)
+		SetQuota(nil)
+	}()
+
+	body, _ := json.Marshal(validReelRequest())
+	post := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		serve(rec, newJSONRequest(http.MethodPost, target, bytes.NewReader(body)))
+		return rec
+	}
+
+	// A failed publish gives its reel back
+	SetPublisher(bus.NewPublisher("queue", failingSQSClient{}))
+	if rec := post("/reels"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	SetPublisher(bus.NewPublisher("queue", client))
+
+	steps := []struct {
+		target string
+		status int
+	}{
+		{"/reels?dryRun=true", http.StatusOK},
+		{"/reels?dryRun=true", http.StatusOK},
+		{"/reels", http.StatusAccepted},
+		{"/reels?dryRun=true", http.StatusTooManyRequests},
+		{"/reels", http.StatusTooManyRequests},
+	}
+	for _, step := range steps {
+		rec := post(step.target)
+		if rec.Code != step.status {
+			t.Fatalf("POST %s: expected status %d, got %d: %s", step.target, step.status, rec.Code, rec.Body.String())
+		}
+		if rec.Code != http.StatusTooManyRequests {
+			continue
+		}
+		var resp models.ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		if resp.Code != "quota_exceeded" || !resp.Retryable || rec.Header().Get("Retry-After") == "" {
+			t.Errorf("Expected retryable quota_exceeded with Retry-After, got %+v, %q", resp, rec.Header().Get("Retry-After"))
+		}
+	}
+	if len(client.sent) != 1 {
+		t.Errorf("Expected one message published, got %d", len(client.sent))
+	}
+}
+
+func TestCreateReel_StrictDecoding(t *testing.T) {
+	SetDecodeOptions(httpx.DecodeOptions{MaxBytes: 2048, Strict: true})
+	defer SetDecodeOptions(httpx.DecodeOptions{Strict: true})
+
+	body, _ := json.Marshal(validReelRequest())
+	withExtra := append(bytes.TrimSuffix(body, []byte("}")), []byte(`,"priority":"high"}`)...)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		status      int
+	}{
+		{name: "unknown field", contentType: "application/json", body: withExtra, status: http.StatusBadRequest},
+		{name: "wrong content type", contentType: "text/plain", body: body, status: http.StatusUnsupportedMediaType},
+		{name: "too large", contentType: "application/json", body: bytes.Repeat([]byte(" "), 4096), status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/reels?dryRun=true", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			serve(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
 	}
 }
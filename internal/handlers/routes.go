@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/wolfman30/api-gateway-go/internal/auth"
+)
+
+// Route is an API endpoint. Scope is the scope a caller must hold; routes
+// without one are public.
+type Route struct {
+	Method  string
+	Pattern string
+	Scope   string
+	Handler http.HandlerFunc
+}
+
+// Routes returns the gateway's API route table.
+func Routes() []Route {
+	return []Route{
+		{Method: http.MethodPost, Pattern: "/reels", Scope: auth.ScopeReelsWrite, Handler: CreateReel},
+		{Method: http.MethodGet, Pattern: "/runs/{runId}", Scope: auth.ScopeRunsRead, Handler: GetRunStatus},
+		{Method: http.MethodPost, Pattern: "/oauth/token", Handler: OAuthToken},
+	}
+}
+
+// KeyRoutes returns the API key administration routes. They are served only
+// when keys are kept in a store shared by every task; otherwise a key issued
+// or revoked through one task would be unknown to the rest.
+func KeyRoutes() []Route {
+	return []Route{
+		{Method: http.MethodGet, Pattern: "/admin/keys", Scope: auth.ScopeKeysAdmin, Handler: ListAPIKeys},
+		{Method: http.MethodPost, Pattern: "/admin/keys", Scope: auth.ScopeKeysAdmin, Handler: CreateAPIKey},
+		{Method: http.MethodDelete, Pattern: "/admin/keys/{id}", Scope: auth.ScopeKeysAdmin, Handler: RevokeAPIKey},
+		{Method: http.MethodPost, Pattern: "/admin/keys/{id}/rotate", Scope: auth.ScopeKeysAdmin, Handler: RotateAPIKey},
+	}
+}
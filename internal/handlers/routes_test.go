@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+	"github.com/wolfman30/api-gateway-go/internal/auth"
+	"github.com/wolfman30/api-gateway-go/internal/router"
+)
+
+// newTestRouter serves the route table without authentication, so tests
+// exercise routing and path parameters the way the server does.
+func newTestRouter() *router.Router {
+	rt := router.New()
+	for _, route := range append(Routes(), KeyRoutes()...) {
+		rt.Handle(route.Method, route.Pattern, route.Handler)
+	}
+	return rt
+}
+
+// serve routes req through newTestRouter as testAdmin, unless req already
+// carries a principal.
+func serve(w http.ResponseWriter, req *http.Request) {
+	newTestRouter().ServeHTTP(w, withTestPrincipal(req))
+}
+
+// testAdmin stands in for the principal auth.Require sets on every request.
+var testAdmin = &auth.Principal{ID: "apikey:test-admin", Admin: true}
+
+// withTestPrincipal authenticates req as testAdmin unless it already has a principal.
+func withTestPrincipal(req *http.Request) *http.Request {
+	if _, ok := auth.PrincipalFromContext(req.Context()); ok {
+		return req
+	}
+	return req.WithContext(auth.WithPrincipal(req.Context(), testAdmin))
+}
+
+// newJSONRequest builds a request with a JSON Content-Type.
+func newJSONRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestRoutes_OnlyTokenEndpointIsPublic(t *testing.T) {
+	for _, route := range append(Routes(), KeyRoutes()...) {
+		if route.Scope == "" && route.Pattern != "/oauth/token" {
+			t.Errorf("Route %s %s has no scope", route.Method, route.Pattern)
+		}
+	}
+}
+
+func TestRoutes_NotFoundAndMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		status int
+		allow  string
+	}{
+		{http.MethodGet, "/runs/abc/def", http.StatusNotFound, ""},
+		{http.MethodGet, "/runs/", http.StatusNotFound, ""},
+		{http.MethodGet, "/unknown", http.StatusNotFound, ""},
+		{http.MethodDelete, "/reels", http.StatusMethodNotAllowed, "POST"},
+		{http.MethodPut, "/admin/keys", http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+		{http.MethodGet, "/admin/keys/abc/rotate", http.StatusMethodNotAllowed, "POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			serve(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Expected Allow %q, got %q", tt.allow, got)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != apierror.ContentType {
+				t.Errorf("Expected problem+json error body, got Content-Type %q", ct)
+			}
+		})
+	}
+}
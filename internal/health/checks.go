@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// QueueAttributesClient is the SQS operation used to probe the queue (for testing).
+type QueueAttributesClient interface {
+	GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+}
+
+// NewSQSCheck verifies the reel command queue exists and is reachable.
+func NewSQSCheck(client QueueAttributesClient, queueURL string) Checker {
+	return NewCheck("sqs", func(ctx context.Context) error {
+		_, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+			QueueUrl:       aws.String(queueURL),
+			AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+		})
+		return err
+	})
+}
+
+// Pinger is implemented by stores that can verify their backend connection.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// NewPingCheck verifies a store such as the run store responds.
+func NewPingCheck(name string, p Pinger) Checker {
+	return NewCheck(name, p.Ping)
+}
+
+// NewSecretsFreshnessCheck fails until secrets have been loaded, and when
+// maxAge is positive, once the last successful load is older than maxAge.
+func NewSecretsFreshnessCheck(loadedAt func() time.Time, maxAge time.Duration) Checker {
+	return NewCheck("secrets", func(ctx context.Context) error {
+		at := loadedAt()
+		if at.IsZero() {
+			return errors.New("secrets not loaded")
+		}
+		if age := time.Since(at); maxAge > 0 && age > maxAge {
+			return fmt.Errorf("secrets last loaded %s ago (max %s)", age.Round(time.Second), maxAge)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check statuses reported in the JSON body.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker verifies that one dependency is usable.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c checkFunc) Name() string                    { return c.name }
+func (c checkFunc) Check(ctx context.Context) error { return c.fn(ctx) }
+
+// NewCheck adapts a function into a Checker.
+func NewCheck(name string, fn func(ctx context.Context) error) Checker {
+	return checkFunc{name: name, fn: fn}
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs float64   `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Report is the JSON body of /readyz.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Registry runs checkers and caches their results so frequent probes do not
+// hammer the dependencies.
+type Registry struct {
+	checkers []Checker
+	cacheTTL time.Duration
+	timeout  time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]Result
+}
+
+// NewRegistry creates a registry whose results are reused for cacheTTL and
+// whose checks are cancelled after timeout.
+func NewRegistry(cacheTTL, timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Registry{
+		cacheTTL: cacheTTL,
+		timeout:  timeout,
+		now:      time.Now,
+		cache:    make(map[string]Result),
+	}
+}
+
+// Register adds a checker. It is not safe to call once probes are being served.
+func (r *Registry) Register(c Checker) {
+	r.checkers = append(r.checkers, c)
+}
+
+// Run returns the result of every check, running stale ones concurrently.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	results := make([]Result, len(r.checkers))
+	var wg sync.WaitGroup
+	for i, c := range r.checkers {
+		if cached, ok := r.cache[c.Name()]; ok && now.Sub(cached.CheckedAt) < r.cacheTTL {
+			results[i] = cached
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, c Checker) {
+			defer wg.Done()
+			results[i] = r.check(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, res := range results {
+		r.cache[res.Name] = res
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (r *Registry) check(ctx context.Context, c Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := r.now()
+	err := c.Check(ctx)
+	res := Result{
+		Name:      c.Name(),
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// ReadyzHandler serves the check report, responding 503 when any check fails
+// or when ready reports false (for example while the server drains).
+func (r *Registry) ReadyzHandler(ready func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+		if ready != nil && !ready() {
+			report.Status = StatusFail
+			report.Checks = append(report.Checks, Result{Name: "server", Status: StatusFail, Error: "shutting down", CheckedAt: r.now()})
+		}
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// LivezHandler reports that the process is up and serving requests. It never
+// checks dependencies, so an outage elsewhere does not restart the task.
+func LivezHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"status": StatusOK})
+}
@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+type fakeQueueClient struct {
+	err   error
+	calls atomic.Int32
+}
+
+func (f *fakeQueueClient) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	f.calls.Add(1)
+	return &sqs.GetQueueAttributesOutput{}, f.err
+}
+
+func TestReadyzHandler(t *testing.T) {
+	queue := &fakeQueueClient{}
+	registry := NewRegistry(time.Minute, time.Second)
+	registry.Register(NewSQSCheck(queue, "https://sqs.us-east-1.amazonaws.com/123456789012/test-queue"))
+	registry.Register(NewSecretsFreshnessCheck(func() time.Time { return time.Now() }, time.Hour))
+
+	rec := httptest.NewRecorder()
+	registry.ReadyzHandler(func() bool { return true })(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Status != StatusOK || len(report.Checks) != 2 || report.Checks[0].Name != "sqs" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	// Failures are cached too, so flip the client and use a fresh registry
+	queue.err = errors.New("AWS.SimpleQueueService.NonExistentQueue")
+	failing := NewRegistry(time.Minute, time.Second)
+	failing.Register(NewSQSCheck(queue, "missing"))
+	rec = httptest.NewRecorder()
+	failing.ReadyzHandler(nil)(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d for failing check, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	json.NewDecoder(rec.Body).Decode(&report)
+	if report.Checks[0].Status != StatusFail || report.Checks[0].Error == "" {
+		t.Errorf("Expected failed sqs check with error, got %+v", report.Checks[0])
+	}
+}
+
+func TestReadyzHandler_NotReady(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewRegistry(time.Minute, time.Second).ReadyzHandler(func() bool { return false })(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d while draining, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+func TestRegistry_CachesResults(t *testing.T) {
+	queue := &fakeQueueClient{}
+	registry := NewRegistry(time.Minute, time.Second)
+	registry.Register(NewSQSCheck(queue, "queue"))
+
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		registry.Run(context.Background())
+	}
+	if got := queue.calls.Load(); got != 1 {
+		t.Errorf("Expected 1 call within cache TTL, got %d", got)
+	}
+
+	now = now.Add(time.Minute)
+	registry.Run(context.Background())
+	if got := queue.calls.Load(); got != 2 {
+		t.Errorf("Expected refresh after cache TTL, got %d calls", got)
+	}
+}
+
+func TestRegistry_TimesOutSlowChecks(t *testing.T) {
+	registry := NewRegistry(0, 10*time.Millisecond)
+	registry.Register(NewCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	report := registry.Run(context.Background())
+	if report.Status != StatusFail || report.Checks[0].Error == "" {
+		t.Errorf("Expected slow check to fail on timeout, got %+v", report)
+	}
+}
+
+func TestSecretsFreshnessCheck(t *testing.T) {
+	ctx := context.Background()
+	if err := NewSecretsFreshnessCheck(func() time.Time { return time.Time{} }, 0).Check(ctx); err == nil {
+		t.Error("Expected error when secrets were never loaded")
+	}
+	if err := NewSecretsFreshnessCheck(func() time.Time { return time.Now().Add(-2 * time.Hour) }, time.Hour).Check(ctx); err == nil {
+		t.Error("Expected error for stale secrets")
+	}
+	if err := NewSecretsFreshnessCheck(func() time.Time { return time.Now().Add(-2 * time.Hour) }, 0).Check(ctx); err != nil {
+		t.Errorf("Expected no age limit when maxAge is 0, got %v", err)
+	}
+}
+
+func TestLivezHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	LivezHandler(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+)
+
+// DefaultMaxBodyBytes limits request bodies when DecodeOptions.MaxBytes is unset.
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// DecodeOptions controls DecodeJSON.
+type DecodeOptions struct {
+	// MaxBytes is the largest accepted body; 0 means DefaultMaxBodyBytes.
+	MaxBytes int64
+	// Strict rejects fields the target type does not declare.
+	Strict bool
+}
+
+// DecodeJSON decodes a single JSON value from the request body into v.
+// The request must be application/json (or a +json type), no larger than
+// opts.MaxBytes, and contain nothing after the value. Failures are returned
+// as *apierror.Error ready to be written to the client.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v any, opts DecodeOptions) error {
+	if !isJSON(r.Header.Get("Content-Type")) {
+		return apierror.New(apierror.CodeUnsupportedMedia, "Content-Type must be application/json")
+	}
+
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	if opts.Strict {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return decodeError(err)
+		}
+		return apierror.New(apierror.CodeInvalidRequest, "Request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// decodeError turns a json.Decoder error into a client error that points at
+// the offending field or offset.
+func decodeError(err error) *apierror.Error {
+	var (
+		tooLarge  *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		return apierror.New(apierror.CodePayloadTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit)).
+			WithDetails(map[string]int64{"maxBytes": tooLarge.Limit})
+	case errors.Is(err, io.EOF):
+		return apierror.New(apierror.CodeInvalidRequest, "Request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apierror.New(apierror.CodeInvalidRequest, "Request body is truncated")
+	case errors.As(err, &syntaxErr):
+		return apierror.New(apierror.CodeInvalidRequest, "Request body is not valid JSON").
+			WithDetails(map[string]int64{"offset": syntaxErr.Offset})
+	case errors.As(err, &typeErr):
+		path := typeErr.Field
+		if path == "" {
+			path = "$"
+		}
+		expected := jsonType(typeErr.Type)
+		return apierror.New(apierror.CodeInvalidRequest, fmt.Sprintf("%s must be %s, not %s", path, expected, typeErr.Value)).
+			WithDetails(map[string]string{"path": path, "expected": expected, "actual": typeErr.Value})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return apierror.New(apierror.CodeInvalidRequest, fmt.Sprintf("Unknown field %q", field)).
+			WithDetails(map[string]string{"field": field})
+	default:
+		return apierror.Wrap(err, apierror.CodeInvalidRequest, "Invalid request body")
+	}
+}
+
+// jsonType names a Go type the way a JSON client would think of it.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	default:
+		return t.String()
+	}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
@@ -0,0 +1,77 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+)
+
+type decodeTarget struct {
+	Name string `json:"name"`
+	ICP  struct {
+		PainPoints []string `json:"painPoints"`
+	} `json:"icp"`
+	Count int `json:"count"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        DecodeOptions
+		code        apierror.Code
+		details     map[string]string
+	}{
+		{name: "valid", contentType: "application/json", body: `{"name":"a","count":2}`},
+		{name: "charset parameter", contentType: "application/json; charset=utf-8", body: `{"name":"a"}`},
+		{name: "json suffix", contentType: "application/merge-patch+json", body: `{"name":"a"}`},
+		{name: "unknown field ignored when lenient", contentType: "application/json", body: `{"name":"a","extra":1}`},
+		{name: "missing content type", body: `{"name":"a"}`, code: apierror.CodeUnsupportedMedia},
+		{name: "form content type", contentType: "application/x-www-form-urlencoded", body: `name=a`, code: apierror.CodeUnsupportedMedia},
+		{name: "too large", contentType: "application/json", body: `{"name":"` + strings.Repeat("a", 64) + `"}`, opts: DecodeOptions{MaxBytes: 16}, code: apierror.CodePayloadTooLarge},
+		{name: "unknown field strict", contentType: "application/json", body: `{"name":"a","extra":1}`, opts: DecodeOptions{Strict: true}, code: apierror.CodeInvalidRequest, details: map[string]string{"field": "extra"}},
+		{name: "nested type error", contentType: "application/json", body: `{"icp":{"painPoints":"slow"}}`, code: apierror.CodeInvalidRequest, details: map[string]string{"path": "icp.painPoints", "expected": "array", "actual": "string"}},
+		{name: "top-level type error", contentType: "application/json", body: `[1]`, code: apierror.CodeInvalidRequest, details: map[string]string{"path": "$", "expected": "object", "actual": "array"}},
+		{name: "trailing data", contentType: "application/json", body: `{"name":"a"} {"name":"b"}`, code: apierror.CodeInvalidRequest},
+		{name: "trailing garbage", contentType: "application/json", body: `{"name":"a"}garbage`, code: apierror.CodeInvalidRequest},
+		{name: "empty body", contentType: "application/json", body: ``, code: apierror.CodeInvalidRequest},
+		{name: "syntax error", contentType: "application/json", body: `{"name":}`, code: apierror.CodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/reels", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var v decodeTarget
+			err := DecodeJSON(httptest.NewRecorder(), req, &v, tt.opts)
+
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var apiErr *apierror.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected *apierror.Error, got %v", err)
+			}
+			if apiErr.Code != tt.code {
+				t.Errorf("Expected code %s, got %s (%s)", tt.code, apiErr.Code, apiErr.Message)
+			}
+			for key, want := range tt.details {
+				details, _ := apiErr.Details.(map[string]string)
+				if details[key] != want {
+					t.Errorf("Expected details[%s] = %q, got %v", key, want, apiErr.Details)
+				}
+			}
+		})
+	}
+}
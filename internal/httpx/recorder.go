@@ -0,0 +1,48 @@
+// Package httpx holds small net/http helpers shared by the gateway's middleware.
+package httpx
+
+import "net/http"
+
+// StatusRecorder wraps a ResponseWriter to capture the status code written by
+// a handler. A handler that never calls WriteHeader is recorded as 200.
+type StatusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// NewStatusRecorder wraps w.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status returns the status code sent to the client.
+func (r *StatusRecorder) Status() int {
+	return r.status
+}
+
+// WroteHeader reports whether the response has started, after which the
+// status can no longer change.
+func (r *StatusRecorder) WroteHeader() bool {
+	return r.wroteHeader
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *StatusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (r *StatusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *StatusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,113 @@
+// Package logging configures structured JSON logging and carries
+// request-scoped loggers through contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/httpx"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Field names shared by every package so log queries work across components.
+const (
+	KeyRequestID = "requestId"
+	KeyRunID     = "runId"
+	KeyProjectID = "projectId"
+	KeyPrincipal = "principal"
+	KeyError     = "error"
+)
+
+// ParseLevel converts a LOG_LEVEL value (debug, info, warn, error) to a slog level.
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", level)
+	}
+}
+
+// New creates a JSON logger writing to w at the given level. Credentials in
+// messages and attributes are masked; see Scrub.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: scrubAttr}))
+}
+
+// Setup installs a JSON logger at level as the slog default, which also
+// routes the standard library log package through it. An unknown level
+// falls back to info and is reported.
+func Setup(w io.Writer, level string) *slog.Logger {
+	lvl, err := ParseLevel(level)
+	logger := New(w, lvl)
+	slog.SetDefault(logger)
+	if err != nil {
+		logger.Warn("Invalid LOG_LEVEL, using info", KeyError, err)
+	}
+	return logger
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger carries the extra attributes.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// Middleware gives each request a logger carrying its method, route,
+// request ID and trace ID, and logs the outcome once the handler returns.
+// It must run inside requestid.Middleware and tracing.Middleware for those
+// IDs to be known.
+func Middleware(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		logger := FromContext(r.Context()).With("method", r.Method, "route", route)
+		if id := requestid.FromContext(r.Context()); id != "" {
+			logger = logger.With(KeyRequestID, id)
+		}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			logger = logger.With("traceId", sc.TraceID().String())
+		}
+		ctx := WithLogger(r.Context(), logger)
+
+		rec := httpx.NewStatusRecorder(w)
+		next(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if rec.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.Log(ctx, level, "Request completed",
+			"path", r.URL.Path,
+			"status", rec.Status(),
+			"durationMs", float64(time.Since(start).Microseconds())/1000,
+			"remoteAddr", r.RemoteAddr,
+		)
+	}
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("log output is not JSON: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"", slog.LevelInfo, false},
+		{"warning", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", slog.LevelInfo, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNew_HonoursLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelWarn)
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 1 || lines[0]["msg"] != "kept" {
+		t.Fatalf("Expected only the warning, got %v", lines)
+	}
+}
+
+func TestWith_AddsFieldsToContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), New(&buf, slog.LevelInfo))
+	ctx = With(ctx, KeyRunID, "run-1", KeyProjectID, "proj-1")
+
+	FromContext(ctx).Info("hello")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d", len(lines))
+	}
+	if lines[0][KeyRunID] != "run-1" || lines[0][KeyProjectID] != "proj-1" {
+		t.Errorf("Expected runId and projectId fields, got %v", lines[0])
+	}
+}
+
+func TestFromContext_DefaultsToSlogDefault(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("Expected the default logger when none is set")
+	}
+}
+
+func TestMiddleware_LogsRequestWithHandlerFields(t *testing.T) {
+	var buf bytes.Buffer
+	handler := Middleware("/runs/{runId}", func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("inside", KeyRunID, "abc")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/runs/abc", nil)
+	req = req.WithContext(WithLogger(req.Context(), New(&buf, slog.LevelInfo)))
+	handler(httptest.NewRecorder(), req)
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %v", len(lines), lines)
+	}
+	if lines[0]["route"] != "/runs/{runId}" || lines[0]["method"] != "GET" {
+		t.Errorf("Expected handler log to carry route and method, got %v", lines[0])
+	}
+	done := lines[1]
+	if done["msg"] != "Request completed" || done["status"] != float64(http.StatusNotFound) || done["path"] != "/runs/abc" {
+		t.Errorf("Unexpected completion line: %v", done)
+	}
+}
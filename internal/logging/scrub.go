@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces credentials in log output.
+const Redacted = "[REDACTED]"
+
+var (
+	// Authorization header values: "Bearer <token>", "Basic <credentials>"
+	authSchemePattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]{8,}=*`)
+	// Gateway API keys, agw_<prefix>_<secret>; the prefix identifies the key and is kept
+	apiKeyPattern = regexp.MustCompile(`\bagw_([0-9a-f]{8,16})_[0-9a-f]+`)
+	// Credentials passed as query parameters
+	queryCredentialPattern = regexp.MustCompile(`(?i)([?&](?:api_key|apikey|access_token|token|client_secret)=)[^&\s"]+`)
+)
+
+var keyNormalizer = strings.NewReplacer("-", "", "_", "")
+
+// sensitiveKeys are attribute keys whose values are always masked, compared
+// in lower case with "-" and "_" removed. "secret" is not one: it names
+// secrets in config logs, whose values are config.Secret and redact themselves.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"xapikey":       true,
+	"apikey":        true,
+	"token":         true,
+	"accesstoken":   true,
+	"clientsecret":  true,
+	"password":      true,
+	"cookie":        true,
+}
+
+// Scrub masks bearer and basic credentials, gateway API keys and credential
+// query parameters in s.
+func Scrub(s string) string {
+	s = authSchemePattern.ReplaceAllString(s, "$1 "+Redacted)
+	s = apiKeyPattern.ReplaceAllString(s, "agw_${1}_"+Redacted)
+	return queryCredentialPattern.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// scrubAttr is a slog ReplaceAttr function that masks credentials in the
+// message, string and error attributes, and the whole value of attributes
+// with a sensitive key such as "authorization".
+func scrubAttr(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[keyNormalizer.Replace(strings.ToLower(a.Key))] {
+		return slog.String(a.Key, Redacted)
+	}
+	var s string
+	switch a.Value.Kind() {
+	case slog.KindString:
+		s = a.Value.String()
+	case slog.KindAny:
+		err, ok := a.Value.Any().(error)
+		if !ok {
+			return a
+		}
+		s = err.Error()
+	default:
+		return a
+	}
+	if scrubbed := Scrub(s); scrubbed != s {
+		return slog.String(a.Key, scrubbed)
+	}
+	return a
+}
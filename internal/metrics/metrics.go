@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/wolfman30/api-gateway-go/internal/httpx"
+)
+
+const namespace = "api_gateway"
+
+// Metrics holds the gateway's Prometheus collectors.
+type Metrics struct {
+	httpRequests    *prometheus.CounterVec
+	httpDuration    *prometheus.HistogramVec
+	publishTotal    *prometheus.CounterVec
+	publishDuration *prometheus.HistogramVec
+	publishRetries  prometheus.Counter
+	runsAccepted    *prometheus.CounterVec
+	panics          *prometheus.CounterVec
+	buildInfo       *prometheus.GaugeVec
+}
+
+// New creates the gateway collectors and registers them with reg. Tests pass
+// a fresh prometheus.NewRegistry(); the server uses its own registry too.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		publishTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sqs_publish_total",
+			Help:      "Reel commands published to SQS by outcome (success or failure).",
+		}, []string{"outcome"}),
+		publishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sqs_publish_duration_seconds",
+			Help:      "Latency of PublishReelCommand by outcome, including SDK retries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		publishRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sqs_publish_retries_total",
+			Help:      "SendMessage attempts beyond the first, as retried by the AWS SDK.",
+		}),
+		runsAccepted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "runs_accepted_total",
+			Help:      "Reel runs accepted by project.",
+		}, []string{"project"}),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_panics_total",
+			Help:      "Handler panics recovered by route.",
+		}, []string{"route"}),
+		buildInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "build_info",
+			Help:      "Build information; the value is always 1.",
+		}, []string{"version", "revision", "goversion"}),
+	}
+
+	reg.MustRegister(m.httpRequests, m.httpDuration, m.publishTotal, m.publishDuration, m.publishRetries, m.runsAccepted, m.panics, m.buildInfo)
+	m.buildInfo.WithLabelValues(buildVersion()).Set(1)
+	return m
+}
+
+// Handler serves the metrics gathered by g in the Prometheus text format.
+func Handler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
+
+// Instrument records request count and latency for next under route, which
+// should be the registered pattern rather than the raw path to keep label
+// cardinality bounded.
+func (m *Metrics) Instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := httpx.NewStatusRecorder(w)
+
+		next(rec, r)
+
+		status := strconv.Itoa(rec.Status())
+		m.httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		m.httpDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// InstrumentHandler records every request to next, including ones rejected
+// before reaching a handler, under the route named by route. route should
+// return a fixed label for unmatched paths, such as router.Unmatched.
+func (m *Metrics) InstrumentHandler(route func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Instrument(route(r), next.ServeHTTP)(w, r)
+	})
+}
+
+// ObservePublish implements bus.PublishObserver.
+func (m *Metrics) ObservePublish(err error, duration time.Duration, retries int) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	m.publishTotal.WithLabelValues(outcome).Inc()
+	m.publishDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+	if retries > 0 {
+		m.publishRetries.Add(float64(retries))
+	}
+}
+
+// RunAccepted counts an accepted reel run for projectID.
+func (m *Metrics) RunAccepted(projectID string) {
+	m.runsAccepted.WithLabelValues(projectID).Inc()
+}
+
+// ReportPanic counts a recovered handler panic.
+func (m *Metrics) ReportPanic(ctx context.Context, route string, value any, stack []byte) {
+	m.panics.WithLabelValues(route).Inc()
+}
+
+func buildVersion() (version, revision, goversion string) {
+	version, revision, goversion = "unknown", "unknown", runtime.Version()
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			revision = setting.Value
+		}
+	}
+	return
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := New(reg)
+
+	handler := m.Instrument("/runs/{runId}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Run not found", http.StatusNotFound)
+	})
+	for i := 0; i < 2; i++ {
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/runs/abc", nil))
+	}
+
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("/runs/{runId}", "GET", "404")); got != 2 {
+		t.Errorf("Expected 2 requests counted, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.httpDuration); got != 1 {
+		t.Errorf("Expected one latency series, got %d", got)
+	}
+}
+
+func TestInstrument_DefaultStatus(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	m.Instrument("/livez", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("/livez", "GET", "200")); got != 1 {
+		t.Errorf("Expected implicit 200 to be counted, got %v", got)
+	}
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	route := func(r *http.Request) string {
+		if r.URL.Path == "/livez" {
+			return "/livez"
+		}
+		return "unmatched"
+	}
+	handler := m.InstrumentHandler(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/livez" {
+			http.NotFound(w, r)
+		}
+	}))
+	for _, path := range []string{"/livez", "/a", "/b/c"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("unmatched", "GET", "404")); got != 2 {
+		t.Errorf("Expected 2 unmatched requests counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("/livez", "GET", "200")); got != 1 {
+		t.Errorf("Expected 1 /livez request counted, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.httpDuration); got != 2 {
+		t.Errorf("Expected two latency series, got %d", got)
+	}
+}
+
+func TestObservePublishAndRuns(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	m.ObservePublish(nil, 20*time.Millisecond, 0)
+	m.ObservePublish(nil, 40*time.Millisecond, 2)
+	m.ObservePublish(errors.New("throttled"), time.Second, 3)
+	m.RunAccepted("proj_1")
+	m.RunAccepted("proj_1")
+
+	if got := testutil.ToFloat64(m.publishTotal.WithLabelValues("success")); got != 2 {
+		t.Errorf("Expected 2 successes, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.publishTotal.WithLabelValues("failure")); got != 1 {
+		t.Errorf("Expected 1 failure, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.publishRetries); got != 5 {
+		t.Errorf("Expected 5 retries, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.runsAccepted.WithLabelValues("proj_1")); got != 2 {
+		t.Errorf("Expected 2 runs for proj_1, got %v", got)
+	}
+}
+
+func TestReportPanic(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	m.ReportPanic(context.Background(), "/reels", "boom", nil)
+
+	if got := testutil.ToFloat64(m.panics.WithLabelValues("/reels")); got != 1 {
+		t.Errorf("Expected 1 panic counted, got %v", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	New(reg)
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "api_gateway_build_info{") {
+		t.Errorf("Expected build info in output, got %s", rec.Body.String())
+	}
+}
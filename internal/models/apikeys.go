@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// CreateAPIKeyRequest is the body of POST /admin/keys.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// APIKeyResponse describes a stored API key. The secret is never included.
+type APIKeyResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// IssuedAPIKeyResponse is returned when a key is created or rotated.
+// Key holds the plaintext, which is shown only this once.
+type IssuedAPIKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey APIKeyResponse `json:"apiKey"`
+}
+
+// ListAPIKeysResponse is returned by GET /admin/keys.
+type ListAPIKeysResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
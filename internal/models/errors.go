@@ -0,0 +1,13 @@
+package models
+
+// ErrorResponse is the application/problem+json body of every error
+// response except those from POST /oauth/token.
+type ErrorResponse struct {
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	Retryable bool   `json:"retryable"`
+}
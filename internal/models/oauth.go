@@ -0,0 +1,16 @@
+package models
+
+// TokenResponse is the RFC 6749 access token response from POST /oauth/token.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// OAuthErrorResponse is the RFC 6749 error response from POST /oauth/token.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	RequestID        string `json:"requestId,omitempty"`
+}
@@ -66,3 +66,18 @@ type RunStep struct {
 	UpdatedAt string   `json:"updatedAt"`
 	Artifacts []string `json:"artifacts,omitempty"`
 }
+
+// DryRunResponse is returned instead of CreateReelResponse when a reel request
+// is submitted in dry-run mode. Nothing is published or persisted.
+type DryRunResponse struct {
+	DryRun  bool               `json:"dryRun"`
+	RunID   string             `json:"runId"`
+	Message ReelCommandMessage `json:"message"`
+}
+
+// ReelCommandMessage is the SQS message that would be sent for a reel command.
+type ReelCommandMessage struct {
+	QueueURL          string            `json:"queueUrl"`
+	MessageBody       string            `json:"messageBody"`
+	MessageAttributes map[string]string `json:"messageAttributes"`
+}
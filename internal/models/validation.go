@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError lists the fields of a request that failed validation.
+type ValidationError struct {
+	Fields []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("missing required fields: %s", strings.Join(e.Fields, ", "))
+}
+
+// Validate checks the fields the OpenAPI schema marks as required.
+func (r *CreateReelRequest) Validate() error {
+	var missing []string
+	require := func(field, value string) {
+		if strings.TrimSpace(value) == "" {
+			missing = append(missing, field)
+		}
+	}
+
+	require("projectId", r.ProjectID)
+	require("icp.industry", r.ICP.Industry)
+	if len(r.ICP.AudiencePainPoints) == 0 {
+		missing = append(missing, "icp.audiencePainPoints")
+	}
+	require("idea", r.Idea)
+	require("fluxModel.loraUrl", r.FluxModel.LoraURL)
+	require("fluxPrompt.prompt", r.FluxPrompt.Prompt)
+	if r.CaptionPreferences != nil && r.CaptionPreferences.CallToAction != nil {
+		require("captionPreferences.callToAction.type", r.CaptionPreferences.CallToAction.Type)
+	}
+
+	if len(missing) > 0 {
+		return &ValidationError{Fields: missing}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package quota
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+)
+
+// Quota items are keyed pk=QUOTA#<project>#<window start>, sk=QUOTA and
+// expire with their window.
+const quotaKeyPrefix = "QUOTA#"
+
+// DynamoCounter is a Counter in the gateway's shared DynamoDB table, so the
+// quota holds across every task. Usage is changed with conditional atomic
+// updates, so concurrent requests cannot overshoot the limit.
+type DynamoCounter struct {
+	table *dynamo.Table
+}
+
+// NewDynamoCounter creates a counter in table.
+func NewDynamoCounter(table *dynamo.Table) *DynamoCounter {
+	return &DynamoCounter{table: table}
+}
+
+// Usage implements Counter.
+func (c *DynamoCounter) Usage(ctx context.Context, key string) (int64, error) {
+	item, err := c.table.Get(ctx, quotaKeyPrefix+key, quotaKeyPrefix)
+	if err != nil {
+		return 0, err
+	}
+	return dynamo.Int(item, "used"), nil
+}
+
+// Add implements Counter. Releasing usage that was never recorded is a no-op.
+func (c *DynamoCounter) Add(ctx context.Context, key string, delta, limit int64, expiresAt time.Time) (int64, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName:                c.table.TableName(),
+		Key:                      dynamo.Key(quotaKeyPrefix+key, quotaKeyPrefix),
+		UpdateExpression:         aws.String("SET expiresAt = :expiresAt ADD #used :delta"),
+		ExpressionAttributeNames: map[string]string{"#used": "used"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":expiresAt": dynamo.ExpiresAt(expiresAt),
+			":delta":     dynamo.N(delta),
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	}
+	if delta > 0 {
+		input.ConditionExpression = aws.String("attribute_not_exists(#used) OR #used <= :max")
+		input.ExpressionAttributeValues[":max"] = dynamo.N(limit - delta)
+	} else {
+		input.ConditionExpression = aws.String("#used >= :min")
+		input.ExpressionAttributeValues[":min"] = dynamo.N(-delta)
+	}
+
+	out, err := c.table.Client.UpdateItem(ctx, input)
+	if dynamo.IsConditionFailed(err) {
+		if delta > 0 {
+			return limit, ErrExceeded
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return dynamo.Int(out.Attributes, "used"), nil
+}
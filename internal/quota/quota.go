@@ -0,0 +1,139 @@
+// Package quota limits how many reels each project may submit per window.
+// Dry runs check the quota without using it; accepted reels consume it.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrExceeded is returned when a project has no quota left in the current window.
+var ErrExceeded = errors.New("quota exceeded")
+
+// Counter stores usage per key. Keys are scoped to one window and may be
+// forgotten once it ends.
+type Counter interface {
+	// Usage returns the usage recorded for key.
+	Usage(ctx context.Context, key string) (int64, error)
+	// Add adds delta to key's usage and returns the new usage. A positive
+	// delta is only applied while the result stays within limit; otherwise
+	// Add fails with ErrExceeded. expiresAt is when the key may be forgotten.
+	Add(ctx context.Context, key string, delta, limit int64, expiresAt time.Time) (int64, error)
+}
+
+// Decision describes a project's quota in the current window.
+type Decision struct {
+	Limit     int64
+	Remaining int64
+	ResetAt   time.Time
+}
+
+// Limiter allows each project Limit reels per fixed Window.
+type Limiter struct {
+	counter Counter
+	limit   int64
+	window  time.Duration
+	now     func() time.Time
+}
+
+// NewLimiter creates a limiter allowing limit reels per project per window.
+func NewLimiter(counter Counter, limit int64, window time.Duration) *Limiter {
+	return &Limiter{counter: counter, limit: limit, window: window, now: time.Now}
+}
+
+// Check reports the project's quota without consuming any. It fails with
+// ErrExceeded when none is left.
+func (l *Limiter) Check(ctx context.Context, project string) (Decision, error) {
+	key, d := l.current(project)
+	used, err := l.counter.Usage(ctx, key)
+	if err != nil {
+		return d, fmt.Errorf("reading quota: %w", err)
+	}
+	d.Remaining = max(l.limit-used, 0)
+	if d.Remaining == 0 {
+		return d, ErrExceeded
+	}
+	return d, nil
+}
+
+// Consume uses one reel of the project's quota, failing with ErrExceeded
+// when none is left.
+func (l *Limiter) Consume(ctx context.Context, project string) (Decision, error) {
+	key, d := l.current(project)
+	used, err := l.counter.Add(ctx, key, 1, l.limit, d.ResetAt)
+	if errors.Is(err, ErrExceeded) {
+		return d, err
+	}
+	if err != nil {
+		return d, fmt.Errorf("consuming quota: %w", err)
+	}
+	d.Remaining = max(l.limit-used, 0)
+	return d, nil
+}
+
+// Release returns a reel consumed by Consume for a request that was not
+// accepted after all.
+func (l *Limiter) Release(ctx context.Context, project string) error {
+	key, d := l.current(project)
+	if _, err := l.counter.Add(ctx, key, -1, l.limit, d.ResetAt); err != nil {
+		return fmt.Errorf("releasing quota: %w", err)
+	}
+	return nil
+}
+
+// current returns the counter key and an empty decision for the project's
+// current window.
+func (l *Limiter) current(project string) (string, Decision) {
+	start := l.now().Truncate(l.window)
+	key := fmt.Sprintf("%s#%d", project, start.Unix())
+	return key, Decision{Limit: l.limit, ResetAt: start.Add(l.window)}
+}
+
+// MemoryCounter is an in-process Counter. Each process counts separately.
+type MemoryCounter struct {
+	mu    sync.Mutex
+	usage map[string]memoryUsage
+}
+
+type memoryUsage struct {
+	used      int64
+	expiresAt time.Time
+}
+
+// NewMemoryCounter creates an empty in-memory counter.
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{usage: make(map[string]memoryUsage)}
+}
+
+// Usage implements Counter.
+func (c *MemoryCounter) Usage(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usage[key].used, nil
+}
+
+// Add implements Counter. Keys from past windows are dropped as new ones are added.
+func (c *MemoryCounter) Add(ctx context.Context, key string, delta, limit int64, expiresAt time.Time) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	u, ok := c.usage[key]
+	if !ok {
+		now := time.Now()
+		for k, old := range c.usage {
+			if now.After(old.expiresAt) {
+				delete(c.usage, k)
+			}
+		}
+	}
+	if delta > 0 && u.used+delta > limit {
+		return u.used, ErrExceeded
+	}
+	u.used = max(u.used+delta, 0)
+	u.expiresAt = expiresAt
+	c.usage[key] = u
+	return u.used, nil
+}
@@ -0,0 +1,61 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo/dynamotest"
+)
+
+func TestLimiter(t *testing.T) {
+	counters := map[string]func() Counter{
+		"memory": func() Counter { return NewMemoryCounter() },
+		"dynamo": func() Counter { return NewDynamoCounter(dynamo.NewTable(dynamotest.New(), "gateway")) },
+	}
+	for name, newCounter := range counters {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// Reads of the shared table compare expiry with the wall clock
+			now := time.Now()
+			l := NewLimiter(newCounter(), 2, time.Hour)
+			l.now = func() time.Time { return now }
+
+			if d, err := l.Check(ctx, "proj_1"); err != nil || d.Remaining != 2 || d.Limit != 2 {
+				t.Fatalf("Expected full quota, got %+v, %v", d, err)
+			}
+			// Checking does not use quota
+			if d, err := l.Check(ctx, "proj_1"); err != nil || d.Remaining != 2 {
+				t.Fatalf("Expected Check not to consume, got %+v, %v", d, err)
+			}
+			for want := int64(1); want >= 0; want-- {
+				if d, err := l.Consume(ctx, "proj_1"); err != nil || d.Remaining != want {
+					t.Fatalf("Expected %d remaining, got %+v, %v", want, d, err)
+				}
+			}
+			if _, err := l.Consume(ctx, "proj_1"); !errors.Is(err, ErrExceeded) {
+				t.Errorf("Expected ErrExceeded consuming past the limit, got %v", err)
+			}
+			if d, err := l.Check(ctx, "proj_1"); !errors.Is(err, ErrExceeded) || d.ResetAt.IsZero() {
+				t.Errorf("Expected ErrExceeded with a reset time checking past the limit, got %+v, %v", d, err)
+			}
+			if _, err := l.Check(ctx, "proj_2"); err != nil {
+				t.Errorf("Expected other projects to be unaffected, got %v", err)
+			}
+
+			if err := l.Release(ctx, "proj_1"); err != nil {
+				t.Fatalf("Expected no error releasing, got %v", err)
+			}
+			if d, err := l.Check(ctx, "proj_1"); err != nil || d.Remaining != 1 {
+				t.Errorf("Expected released quota to be usable, got %+v, %v", d, err)
+			}
+
+			now = now.Add(time.Hour)
+			if d, err := l.Check(ctx, "proj_1"); err != nil || d.Remaining != 2 {
+				t.Errorf("Expected quota to reset in the next window, got %+v, %v", d, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Package recovery turns handler panics into 500 responses instead of
+// dropped connections, and reports them.
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+	"github.com/wolfman30/api-gateway-go/internal/httpx"
+	"github.com/wolfman30/api-gateway-go/internal/logging"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Reporter receives recovered panics, for example to count them or forward
+// them to an error-tracking service. Implementations must not panic.
+type Reporter interface {
+	ReportPanic(ctx context.Context, route string, value any, stack []byte)
+}
+
+// ReporterFunc adapts a function to Reporter.
+type ReporterFunc func(ctx context.Context, route string, value any, stack []byte)
+
+// ReportPanic implements Reporter.
+func (f ReporterFunc) ReportPanic(ctx context.Context, route string, value any, stack []byte) {
+	f(ctx, route, value, stack)
+}
+
+// Recoverer recovers panics in the handlers it wraps.
+type Recoverer struct {
+	mu        sync.RWMutex
+	reporters []Reporter
+}
+
+// New creates a Recoverer with no reporters.
+func New() *Recoverer {
+	return &Recoverer{}
+}
+
+// AddReporter registers a reporter for recovered panics.
+func (rc *Recoverer) AddReporter(r Reporter) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.reporters = append(rc.reporters, r)
+}
+
+// Middleware recovers a panic in next, logs it with its stack, reports it and
+// replies with a 500 internal_error carrying the request ID. If the response
+// had already started, the connection is aborted instead so the client does
+// not mistake a truncated body for a complete one. http.ErrAbortHandler is
+// passed through untouched.
+func (rc *Recoverer) Middleware(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := httpx.NewStatusRecorder(w)
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if err, ok := value.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(value)
+			}
+
+			stack := debug.Stack()
+			ctx := r.Context()
+			// Outside requestid.Middleware the ID is only on the response
+			if id := rec.Header().Get(requestid.Header); id != "" && requestid.FromContext(ctx) == "" {
+				ctx = requestid.WithRequestID(ctx, id)
+				ctx = logging.With(ctx, logging.KeyRequestID, id)
+				r = r.WithContext(ctx)
+			}
+			logging.FromContext(ctx).Error("Panic recovered",
+				"panic", fmt.Sprint(value),
+				"stack", string(stack),
+			)
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(fmt.Errorf("panic: %v", value))
+			span.SetStatus(codes.Error, "panic")
+			rc.report(ctx, route, value, stack)
+
+			if rec.WroteHeader() {
+				panic(http.ErrAbortHandler)
+			}
+			apierror.Write(rec, r, apierror.New(apierror.CodeInternal, "Internal error"))
+		}()
+
+		next(rec, r)
+	}
+}
+
+// Handler recovers panics anywhere in next, including middleware that runs
+// before routing and routes not wrapped by Middleware, as Middleware does.
+// route names the request in logs and reports.
+func (rc *Recoverer) Handler(route func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc.Middleware(route(r), next.ServeHTTP)(w, r)
+	})
+}
+
+func (rc *Recoverer) report(ctx context.Context, route string, value any, stack []byte) {
+	rc.mu.RLock()
+	reporters := rc.reporters
+	rc.mu.RUnlock()
+	for _, r := range reporters {
+		r.ReportPanic(ctx, route, value, stack)
+	}
+}
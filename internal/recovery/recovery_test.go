@@ -0,0 +1,134 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+	"github.com/wolfman30/api-gateway-go/internal/logging"
+	"github.com/wolfman30/api-gateway-go/internal/models"
+	"github.com/wolfman30/api-gateway-go/internal/requestid"
+)
+
+type recordingReporter struct {
+	route string
+	value any
+	stack []byte
+}
+
+func (r *recordingReporter) ReportPanic(ctx context.Context, route string, value any, stack []byte) {
+	r.route, r.value, r.stack = route, value, stack
+}
+
+func TestMiddleware_RecoversPanic(t *testing.T) {
+	var logs bytes.Buffer
+	reporter := &recordingReporter{}
+	rc := New()
+	rc.AddReporter(reporter)
+
+	handler := rc.Middleware("/runs/{runId}", func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map write")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/runs/abc", nil)
+	ctx := requestid.WithRequestID(req.Context(), "req-9")
+	ctx = logging.WithLogger(ctx, logging.New(&logs, slog.LevelInfo))
+	rec := httptest.NewRecorder()
+	handler(rec, req.WithContext(ctx))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != apierror.ContentType {
+		t.Errorf("Expected problem+json, got %q", ct)
+	}
+	var body models.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if body.Code != string(apierror.CodeInternal) || body.RequestID != "req-9" {
+		t.Errorf("Unexpected body %+v", body)
+	}
+	if strings.Contains(rec.Body.String(), "nil map write") {
+		t.Error("Expected panic value not to leak to the client")
+	}
+
+	if reporter.route != "/runs/{runId}" || reporter.value != "nil map write" || len(reporter.stack) == 0 {
+		t.Errorf("Expected panic to be reported, got %+v", reporter)
+	}
+
+	var line map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one JSON log line, got %q", logs.String())
+	}
+	if line["panic"] != "nil map write" || !strings.Contains(line["stack"].(string), "recovery_test.go") {
+		t.Errorf("Expected panic and stack in log, got %v", line)
+	}
+}
+
+func TestMiddleware_AbortsStartedResponse(t *testing.T) {
+	handler := New().Middleware("/reels", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"partial":`))
+		panic(errors.New("encoder failed"))
+	})
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("Expected http.ErrAbortHandler, got %v", v)
+		}
+	}()
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/reels", nil))
+}
+
+func TestMiddleware_PassesThroughWithoutPanic(t *testing.T) {
+	called := false
+	rc := New()
+	rc.AddReporter(ReporterFunc(func(context.Context, string, any, []byte) { called = true }))
+
+	rec := httptest.NewRecorder()
+	rc.Middleware("/reels", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})(rec, httptest.NewRequest(http.MethodPost, "/reels", nil))
+
+	if rec.Code != http.StatusAccepted || called {
+		t.Errorf("Expected untouched response and no report, got %d reported=%v", rec.Code, called)
+	}
+}
+
+func TestHandler_RecoversOutsideRoutes(t *testing.T) {
+	reporter := &recordingReporter{}
+	rc := New()
+	rc.AddReporter(reporter)
+
+	// A panic in middleware that runs after requestid.Middleware but before routing
+	handler := rc.Handler(func(*http.Request) string { return "unmatched" }, requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("cors misconfigured")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/nope", nil)
+	req.Header.Set(requestid.Header, "req-7")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+	var body models.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if body.Code != string(apierror.CodeInternal) || body.RequestID != "req-7" {
+		t.Errorf("Expected internal_error with the request ID, got %+v", body)
+	}
+	if reporter.route != "unmatched" || reporter.value != "cors misconfigured" {
+		t.Errorf("Expected panic to be reported under the given route, got %+v", reporter)
+	}
+}
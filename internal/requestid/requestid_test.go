@@ -0,0 +1,48 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		reuse    bool
+	}{
+		{"reuses caller ID", "req-123_abc.def:1", true},
+		{"generates when missing", "", false},
+		{"replaces unsafe ID", "bad id\nwith newline", false},
+		{"replaces oversized ID", strings.Repeat("a", maxLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/reels", nil)
+			if tt.incoming != "" {
+				req.Header.Set(Header, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(Header)
+			if got == "" || got != seen {
+				t.Fatalf("Expected response header %q to match context ID %q", got, seen)
+			}
+			if tt.reuse && got != tt.incoming {
+				t.Errorf("Expected caller ID %q to be reused, got %q", tt.incoming, got)
+			}
+			if !tt.reuse && got == tt.incoming {
+				t.Errorf("Expected a generated ID, got caller's %q", got)
+			}
+		})
+	}
+}
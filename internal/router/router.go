@@ -0,0 +1,111 @@
+// Package router dispatches requests using Go 1.22 path patterns and
+// answers unknown paths and methods with the gateway's problem+json errors.
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+)
+
+// Unmatched stands in for the route of requests that match no pattern, so
+// metric and log labels stay bounded whatever paths are requested.
+const Unmatched = "unmatched"
+
+// Route is one registered method and path pattern.
+type Route struct {
+	Method  string
+	Pattern string
+}
+
+// Router matches requests by path with an http.ServeMux, so path
+// parameters are available through r.PathValue, and then by method.
+// Routes must all be registered before the router starts serving.
+type Router struct {
+	mux     *http.ServeMux
+	methods map[string]map[string]http.HandlerFunc
+	routes  []Route
+}
+
+// New creates an empty router. Paths that match no route get a 404 not_found error.
+func New() *Router {
+	rt := &Router{
+		mux:     http.NewServeMux(),
+		methods: make(map[string]map[string]http.HandlerFunc),
+	}
+	rt.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.New(apierror.CodeNotFound, "No route matches "+r.URL.Path))
+	})
+	return rt
+}
+
+// Handle registers h for method requests to pattern, a path pattern such as
+// "/runs/{runId}". It panics on a duplicate method and pattern, like
+// http.ServeMux does.
+func (rt *Router) Handle(method, pattern string, h http.HandlerFunc) {
+	byMethod, ok := rt.methods[pattern]
+	if !ok {
+		byMethod = make(map[string]http.HandlerFunc)
+		rt.methods[pattern] = byMethod
+		rt.mux.HandleFunc(pattern, dispatch(byMethod))
+	}
+	if _, dup := byMethod[method]; dup {
+		panic(fmt.Sprintf("router: duplicate route %s %s", method, pattern))
+	}
+	byMethod[method] = h
+	rt.routes = append(rt.routes, Route{Method: method, Pattern: pattern})
+}
+
+// Routes returns the route table in registration order.
+func (rt *Router) Routes() []Route {
+	return slices.Clone(rt.routes)
+}
+
+// Pattern returns the pattern of the route r is dispatched to, whether or not
+// its method is allowed, or Unmatched when no route matches its path.
+func (rt *Router) Pattern(r *http.Request) string {
+	_, pattern := rt.mux.Handler(r)
+	if _, ok := rt.methods[pattern]; !ok {
+		return Unmatched
+	}
+	return pattern
+}
+
+// ServeHTTP implements http.Handler.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// dispatch picks the handler for the request method, serving HEAD with the
+// GET handler, and otherwise replies 405 with an Allow header.
+func dispatch(byMethod map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h, ok := byMethod[r.Method]
+		if !ok && r.Method == http.MethodHead {
+			h, ok = byMethod[http.MethodGet]
+		}
+		if !ok {
+			w.Header().Set("Allow", strings.Join(allowed(byMethod), ", "))
+			apierror.Write(w, r, apierror.New(apierror.CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path))
+			return
+		}
+		h(w, r)
+	}
+}
+
+func allowed(byMethod map[string]http.HandlerFunc) []string {
+	methods := make([]string, 0, len(byMethod)+1)
+	for method := range byMethod {
+		methods = append(methods, method)
+	}
+	if _, ok := byMethod[http.MethodGet]; ok {
+		if _, ok := byMethod[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	slices.Sort(methods)
+	return methods
+}
@@ -0,0 +1,114 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wolfman30/api-gateway-go/internal/models"
+)
+
+func newTestRouter() *Router {
+	rt := New()
+	rt.Handle(http.MethodGet, "/runs/{runId}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("runId")))
+	})
+	rt.Handle(http.MethodPost, "/admin/keys/{id}/rotate", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("rotated " + r.PathValue("id")))
+	})
+	rt.Handle(http.MethodDelete, "/runs/{runId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return rt
+}
+
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		body   string
+		allow  string
+	}{
+		{name: "path parameter", method: http.MethodGet, target: "/runs/abc", status: http.StatusOK, body: "abc"},
+		{name: "head served by get", method: http.MethodHead, target: "/runs/abc", status: http.StatusOK},
+		{name: "nested parameter", method: http.MethodPost, target: "/admin/keys/k1/rotate", status: http.StatusOK, body: "rotated k1"},
+		{name: "extra segment", method: http.MethodGet, target: "/runs/abc/def", status: http.StatusNotFound},
+		{name: "missing parameter", method: http.MethodGet, target: "/runs/", status: http.StatusNotFound},
+		{name: "unknown path", method: http.MethodGet, target: "/nope", status: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodPut, target: "/runs/abc", status: http.StatusMethodNotAllowed, allow: "DELETE, GET, HEAD"},
+	}
+
+	rt := newTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rt.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, rec.Body.String())
+			}
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Expected Allow %q, got %q", tt.allow, got)
+			}
+			if tt.status >= http.StatusBadRequest {
+				var resp models.ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Code == "" {
+					t.Errorf("Expected JSON error body, got %q (%v)", rec.Body.String(), err)
+				}
+			}
+		})
+	}
+}
+
+func TestRouter_Pattern(t *testing.T) {
+	rt := newTestRouter()
+	tests := map[string]string{
+		"GET /runs/abc":              "/runs/{runId}",
+		"PUT /runs/abc":              "/runs/{runId}",
+		"POST /admin/keys/k1/rotate": "/admin/keys/{id}/rotate",
+		"GET /runs/abc/def":          Unmatched,
+		"GET /":                      Unmatched,
+	}
+	for request, want := range tests {
+		method, target, _ := strings.Cut(request, " ")
+		if got := rt.Pattern(httptest.NewRequest(method, target, nil)); got != want {
+			t.Errorf("Pattern(%s) = %q, want %q", request, got, want)
+		}
+	}
+}
+
+func TestRouter_Routes(t *testing.T) {
+	routes := newTestRouter().Routes()
+	want := []Route{
+		{http.MethodGet, "/runs/{runId}"},
+		{http.MethodPost, "/admin/keys/{id}/rotate"},
+		{http.MethodDelete, "/runs/{runId}"},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("Expected %d routes, got %v", len(want), routes)
+	}
+	for i := range want {
+		if routes[i] != want[i] {
+			t.Errorf("Route %d: expected %v, got %v", i, want[i], routes[i])
+		}
+	}
+}
+
+func TestRouter_DuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate registration to panic")
+		}
+	}()
+	rt := New()
+	h := func(http.ResponseWriter, *http.Request) {}
+	rt.Handle(http.MethodGet, "/reels", h)
+	rt.Handle(http.MethodGet, "/reels", h)
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/apierror"
+)
+
+// Config controls the HTTP server's timeouts and shutdown behaviour.
+type Config struct {
+	Addr              string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// TLSConfig, when set, serves HTTPS. It must supply certificates through
+	// Certificates or GetCertificate.
+	TLSConfig *tls.Config
+
+	// DrainPeriod is how long readiness reports failure before the listener
+	// closes, giving load balancers time to stop routing new requests.
+	DrainPeriod time.Duration
+	// ShutdownTimeout bounds how long in-flight requests may take to finish.
+	ShutdownTimeout time.Duration
+}
+
+// Server wraps http.Server with readiness tracking and ordered shutdown.
+type Server struct {
+	cfg        Config
+	httpServer *http.Server
+	ready      atomic.Bool
+
+	mu         sync.Mutex
+	onShutdown []func(context.Context) error
+}
+
+// New creates a server for handler. It reports ready once Serve starts.
+func New(cfg Config, handler http.Handler) *Server {
+	return &Server{
+		cfg: cfg,
+		httpServer: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			TLSConfig:         cfg.TLSConfig,
+		},
+	}
+}
+
+// OnShutdown registers fn to run after the HTTP server has drained, such as
+// closing the publisher or stopping background consumers. Functions run in
+// reverse registration order.
+func (s *Server) OnShutdown(fn func(context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Ready reports whether the server is accepting traffic.
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// ReadinessHandler responds 200 while the server is ready and 503 once
+// shutdown has begun.
+func (s *Server) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.Ready() {
+		apierror.Write(w, r, apierror.New(apierror.CodeShuttingDown, "Shutting down"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// Run listens on the configured address and serves until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is cancelled, then shuts down:
+// readiness fails first, the drain period elapses, in-flight requests finish
+// (up to ShutdownTimeout) and finally the OnShutdown hooks run.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.cfg.TLSConfig != nil {
+			serveErr <- s.httpServer.ServeTLS(ln, "", "")
+			return
+		}
+		serveErr <- s.httpServer.Serve(ln)
+	}()
+	s.ready.Store(true)
+
+	select {
+	case err := <-serveErr:
+		s.ready.Store(false)
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutdown requested, draining", "drainPeriod", s.cfg.DrainPeriod.String())
+	s.ready.Store(false)
+	if s.cfg.DrainPeriod > 0 {
+		time.Sleep(s.cfg.DrainPeriod)
+	}
+
+	shutdownCtx := context.Background()
+	if s.cfg.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, s.cfg.ShutdownTimeout)
+		defer cancel()
+	}
+
+	var errs []error
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HTTP server did not drain cleanly", "error", err)
+		errs = append(errs, err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+
+	s.mu.Lock()
+	hooks := s.onShutdown
+	s.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](shutdownCtx); err != nil {
+			slog.Error("Shutdown hook failed", "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	slog.Info("Shutdown complete")
+	return errors.Join(errs...)
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/models"
+)
+
+var (
+	// ErrRunNotFound is returned when no run exists for a run ID.
+	ErrRunNotFound = errors.New("run not found")
+	// ErrRunExists is returned when creating a run whose ID is taken.
+	ErrRunExists = errors.New("run already exists")
+)
+
+// DefaultRunTTL is how long runs are kept when no TTL is configured.
+const DefaultRunTTL = 7 * 24 * time.Hour
+
+// Run is the gateway's record of an accepted reel run.
+type Run struct {
+	RunID     string
+	ProjectID string
+	Status    string
+	Steps     []models.RunStep
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RunStore persists runs so their status and owning project can be looked up.
+type RunStore interface {
+	CreateRun(ctx context.Context, run *Run) error
+	GetRun(ctx context.Context, runID string) (*Run, error)
+	DeleteRun(ctx context.Context, runID string) error
+	// Ping verifies the store's backend is reachable.
+	Ping(ctx context.Context) error
+}
+
+// MemoryRunStoreOptions bound the memory an in-memory run store can use.
+type MemoryRunStoreOptions struct {
+	// TTL is how long a run is kept after it is created.
+	TTL time.Duration
+	// MaxRuns caps the number of runs kept; the oldest are evicted first.
+	MaxRuns int
+}
+
+// MemoryRunStore is an in-process RunStore for local development and tests.
+// Runs created by other processes are not visible, so deployed environments
+// use DynamoRunStore.
+type MemoryRunStore struct {
+	opts MemoryRunStoreOptions
+	now  func() time.Time
+
+	mu   sync.RWMutex
+	runs map[string]*Run
+	// order holds run IDs by creation time, for eviction
+	order []string
+}
+
+// NewMemoryRunStore creates an empty in-memory run store. Zero options
+// default to DefaultRunTTL and 10000 runs.
+func NewMemoryRunStore(opts MemoryRunStoreOptions) *MemoryRunStore {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultRunTTL
+	}
+	if opts.MaxRuns <= 0 {
+		opts.MaxRuns = 10000
+	}
+	return &MemoryRunStore{opts: opts, now: time.Now, runs: make(map[string]*Run)}
+}
+
+// CreateRun stores a new run, evicting expired runs and, when the store is
+// full, the oldest ones.
+func (s *MemoryRunStore) CreateRun(ctx context.Context, run *Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if existing, exists := s.runs[run.RunID]; exists {
+		if !s.expired(existing, now) {
+			return ErrRunExists
+		}
+		s.remove(run.RunID)
+	}
+	s.evict(now)
+	stored := cloneRun(run)
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = now
+	}
+	s.runs[run.RunID] = stored
+	s.order = append(s.order, run.RunID)
+	return nil
+}
+
+// evict drops expired runs and then the oldest until there is room for one more.
+func (s *MemoryRunStore) evict(now time.Time) {
+	for len(s.order) > 0 {
+		id := s.order[0]
+		run, ok := s.runs[id]
+		if ok && !s.expired(run, now) && len(s.runs) < s.opts.MaxRuns {
+			return
+		}
+		s.order = s.order[1:]
+		delete(s.runs, id)
+	}
+}
+
+func (s *MemoryRunStore) expired(run *Run, now time.Time) bool {
+	return now.Sub(run.CreatedAt) >= s.opts.TTL
+}
+
+// GetRun returns the run with the given ID.
+func (s *MemoryRunStore) GetRun(ctx context.Context, runID string) (*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	run, ok := s.runs[runID]
+	if !ok || s.expired(run, s.now()) {
+		return nil, ErrRunNotFound
+	}
+	return cloneRun(run), nil
+}
+
+// DeleteRun removes a run. Deleting a missing run is not an error.
+func (s *MemoryRunStore) DeleteRun(ctx context.Context, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(runID)
+	return nil
+}
+
+func (s *MemoryRunStore) remove(runID string) {
+	if _, ok := s.runs[runID]; ok {
+		delete(s.runs, runID)
+		s.order = slices.DeleteFunc(s.order, func(id string) bool { return id == runID })
+	}
+}
+
+// Ping always succeeds for the in-memory store.
+func (s *MemoryRunStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func cloneRun(run *Run) *Run {
+	copied := *run
+	copied.Steps = slices.Clone(run.Steps)
+	return &copied
+}
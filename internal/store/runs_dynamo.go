@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+	"github.com/wolfman30/api-gateway-go/internal/models"
+)
+
+// Run items are keyed pk=RUN#<runId>, sk=RUN. The orchestrator updates the
+// status, steps (a JSON array of models.RunStep) and updatedAt attributes
+// as the run progresses.
+const (
+	runKeyPrefix = "RUN#"
+	runSortKey   = "RUN"
+)
+
+// DynamoRunStore is a RunStore in the gateway's shared DynamoDB table, so a
+// run accepted by one task can be read through any other and survives
+// restarts. Runs expire ttl after they are created.
+type DynamoRunStore struct {
+	table *dynamo.Table
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// NewDynamoRunStore creates a run store in table. A non-positive ttl uses
+// DefaultRunTTL.
+func NewDynamoRunStore(table *dynamo.Table, ttl time.Duration) *DynamoRunStore {
+	if ttl <= 0 {
+		ttl = DefaultRunTTL
+	}
+	return &DynamoRunStore{table: table, ttl: ttl, now: time.Now}
+}
+
+// CreateRun stores a new run.
+func (s *DynamoRunStore) CreateRun(ctx context.Context, run *Run) error {
+	steps, err := json.Marshal(run.Steps)
+	if err != nil {
+		return fmt.Errorf("encoding run steps: %w", err)
+	}
+	createdAt := run.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = s.now()
+	}
+	updatedAt := run.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
+
+	_, err = s.table.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: s.table.TableName(),
+		Item: map[string]types.AttributeValue{
+			dynamo.AttrPK:        dynamo.S(runKeyPrefix + run.RunID),
+			dynamo.AttrSK:        dynamo.S(runSortKey),
+			dynamo.AttrExpiresAt: dynamo.ExpiresAt(createdAt.Add(s.ttl)),
+			"projectId":          dynamo.S(run.ProjectID),
+			"status":             dynamo.S(run.Status),
+			"steps":              dynamo.S(string(steps)),
+			"createdAt":          dynamo.Time(createdAt),
+			"updatedAt":          dynamo.Time(updatedAt),
+		},
+		// An expired run awaiting TTL deletion may be replaced
+		ConditionExpression:       aws.String("attribute_not_exists(pk) OR expiresAt < :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":now": dynamo.N(s.now().Unix())},
+	})
+	if dynamo.IsConditionFailed(err) {
+		return ErrRunExists
+	}
+	if err != nil {
+		return fmt.Errorf("writing run %s: %w", run.RunID, err)
+	}
+	return nil
+}
+
+// GetRun returns the run with the given ID.
+func (s *DynamoRunStore) GetRun(ctx context.Context, runID string) (*Run, error) {
+	item, err := s.table.Get(ctx, runKeyPrefix+runID, runSortKey)
+	if err != nil {
+		return nil, fmt.Errorf("reading run %s: %w", runID, err)
+	}
+	if item == nil {
+		return nil, ErrRunNotFound
+	}
+
+	run := &Run{
+		RunID:     runID,
+		ProjectID: dynamo.String(item, "projectId"),
+		Status:    dynamo.String(item, "status"),
+		CreatedAt: dynamo.TimeValue(item, "createdAt"),
+		UpdatedAt: dynamo.TimeValue(item, "updatedAt"),
+	}
+	if steps := dynamo.String(item, "steps"); steps != "" {
+		var decoded []models.RunStep
+		if err := json.Unmarshal([]byte(steps), &decoded); err != nil {
+			return nil, fmt.Errorf("decoding steps of run %s: %w", runID, err)
+		}
+		run.Steps = decoded
+	}
+	return run, nil
+}
+
+// DeleteRun removes a run. Deleting a missing run is not an error.
+func (s *DynamoRunStore) DeleteRun(ctx context.Context, runID string) error {
+	_, err := s.table.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: s.table.TableName(),
+		Key:       dynamo.Key(runKeyPrefix+runID, runSortKey),
+	})
+	if err != nil {
+		return fmt.Errorf("deleting run %s: %w", runID, err)
+	}
+	return nil
+}
+
+// Ping checks the table is reachable.
+func (s *DynamoRunStore) Ping(ctx context.Context) error {
+	return s.table.Ping(ctx)
+}
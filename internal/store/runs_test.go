@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wolfman30/api-gateway-go/internal/dynamo"
+	"github.com/wolfman30/api-gateway-go/internal/dynamo/dynamotest"
+	"github.com/wolfman30/api-gateway-go/internal/models"
+)
+
+func TestRunStores(t *testing.T) {
+	stores := map[string]func() RunStore{
+		"memory": func() RunStore { return NewMemoryRunStore(MemoryRunStoreOptions{}) },
+		"dynamo": func() RunStore { return NewDynamoRunStore(dynamo.NewTable(dynamotest.New(), "gateway"), 0) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newStore()
+
+			run := &Run{RunID: "run-1", ProjectID: "proj_1", Status: "PENDING", Steps: []models.RunStep{{Name: "flux"}}}
+			if err := s.CreateRun(ctx, run); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if err := s.CreateRun(ctx, run); !errors.Is(err, ErrRunExists) {
+				t.Errorf("Expected ErrRunExists creating duplicate run, got %v", err)
+			}
+
+			// Stored runs are isolated from caller mutation
+			run.Steps[0].Name = "mutated"
+			got, err := s.GetRun(ctx, "run-1")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got.ProjectID != "proj_1" || got.Status != "PENDING" || len(got.Steps) != 1 || got.Steps[0].Name != "flux" || got.CreatedAt.IsZero() {
+				t.Errorf("Unexpected run %+v", got)
+			}
+
+			if err := s.DeleteRun(ctx, "run-1"); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if _, err := s.GetRun(ctx, "run-1"); !errors.Is(err, ErrRunNotFound) {
+				t.Errorf("Expected ErrRunNotFound, got %v", err)
+			}
+			if err := s.Ping(ctx); err != nil {
+				t.Errorf("Expected ping to succeed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMemoryRunStore_Bounded(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := NewMemoryRunStore(MemoryRunStoreOptions{TTL: time.Hour, MaxRuns: 3})
+	s.now = func() time.Time { return now }
+
+	for i := range 4 {
+		if err := s.CreateRun(ctx, &Run{RunID: fmt.Sprintf("run-%d", i)}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if _, err := s.GetRun(ctx, "run-0"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("Expected the oldest run to be evicted past MaxRuns, got %v", err)
+	}
+	if _, err := s.GetRun(ctx, "run-3"); err != nil {
+		t.Errorf("Expected the newest run to be kept, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := s.GetRun(ctx, "run-3"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("Expected run to expire after the TTL, got %v", err)
+	}
+	if err := s.CreateRun(ctx, &Run{RunID: "run-3"}); err != nil {
+		t.Fatalf("Expected an expired run ID to be reusable, got %v", err)
+	}
+	if len(s.runs) != 1 || len(s.order) != 1 {
+		t.Errorf("Expected expired runs to be evicted, got %d runs and %d ordered IDs", len(s.runs), len(s.order))
+	}
+}
+
+func TestDynamoRunStore_Expiry(t *testing.T) {
+	ctx := context.Background()
+	// Reads compare expiresAt with the wall clock, so the test clock starts there
+	now := time.Now()
+	s := NewDynamoRunStore(dynamo.NewTable(dynamotest.New(), "gateway"), time.Hour)
+	s.now = func() time.Time { return now }
+
+	if err := s.CreateRun(ctx, &Run{RunID: "run-1", ProjectID: "proj_1"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Runs left for TTL deletion can be replaced
+	now = now.Add(2 * time.Hour)
+	if err := s.CreateRun(ctx, &Run{RunID: "run-1", ProjectID: "proj_2"}); err != nil {
+		t.Fatalf("Expected an expired run to be replaceable, got %v", err)
+	}
+	got, err := s.GetRun(ctx, "run-1")
+	if err != nil || got.ProjectID != "proj_2" {
+		t.Errorf("Expected the replacement run, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,75 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Source loads a PEM certificate chain and private key. version changes
+// whenever the material does, so unchanged material is not re-parsed.
+type Source interface {
+	Load(ctx context.Context) (certPEM, keyPEM []byte, version string, err error)
+}
+
+// FileSource reads the certificate and key from files, as mounted by
+// cert-manager or written by an ACME client.
+type FileSource struct {
+	CertFile string
+	KeyFile  string
+}
+
+// Load implements Source. The version is a hash of both files.
+func (s FileSource) Load(ctx context.Context) ([]byte, []byte, string, error) {
+	certPEM, err := os.ReadFile(s.CertFile)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("reading certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(s.KeyFile)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("reading private key: %w", err)
+	}
+	sum := sha256.New()
+	sum.Write(certPEM)
+	sum.Write(keyPEM)
+	return certPEM, keyPEM, hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// SecretValueClient is the Secrets Manager operation SecretsManagerSource needs.
+type SecretValueClient interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// SecretsManagerSource reads a secret holding
+// {"certificate": "<PEM chain>", "privateKey": "<PEM key>"}.
+type SecretsManagerSource struct {
+	Client   SecretValueClient
+	SecretID string
+}
+
+// Load implements Source. The version is the secret's VersionId, which
+// changes on every rotation.
+func (s SecretsManagerSource) Load(ctx context.Context) ([]byte, []byte, string, error) {
+	out, err := s.Client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(s.SecretID)})
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("fetching certificate secret %s: %w", s.SecretID, err)
+	}
+	var secret struct {
+		Certificate string `json:"certificate"`
+		PrivateKey  string `json:"privateKey"`
+	}
+	if err := json.Unmarshal([]byte(aws.ToString(out.SecretString)), &secret); err != nil {
+		return nil, nil, "", fmt.Errorf("decoding certificate secret %s: %w", s.SecretID, err)
+	}
+	if secret.Certificate == "" || secret.PrivateKey == "" {
+		return nil, nil, "", errors.New("certificate secret requires certificate and privateKey")
+	}
+	return []byte(secret.Certificate), []byte(secret.PrivateKey), aws.ToString(out.VersionId), nil
+}
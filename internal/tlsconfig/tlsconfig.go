@@ -0,0 +1,153 @@
+// Package tlsconfig builds the server's TLS configuration: certificates that
+// reload when rotated, a minimum protocol version and optional client
+// certificate verification for mutual TLS.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader serves the current certificate from a Source and swaps in new
+// material when the source changes.
+type Reloader struct {
+	source  Source
+	cert    atomic.Pointer[tls.Certificate]
+	mu      sync.Mutex
+	version string
+}
+
+// NewReloader loads the initial certificate, which must be valid.
+func NewReloader(ctx context.Context, source Source) (*Reloader, error) {
+	r := &Reloader{source: source}
+	if _, err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload fetches the source and swaps the certificate if it changed. On
+// error the previous certificate stays in use.
+func (r *Reloader) Reload(ctx context.Context) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	certPEM, keyPEM, version, err := r.source.Load(ctx)
+	if err != nil {
+		return false, err
+	}
+	if version != "" && version == r.version {
+		return false, nil
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("parsing certificate: %w", err)
+	}
+	r.cert.Store(&cert)
+	r.version = version
+	return true, nil
+}
+
+// Watch reloads every interval until ctx is cancelled.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.Reload(ctx)
+			if err != nil {
+				slog.Warn("TLS certificate reload failed, keeping current certificate", "error", err)
+				continue
+			}
+			if changed {
+				slog.Info("TLS certificate reloaded", "notAfter", r.Certificate().Leaf.NotAfter)
+			}
+		}
+	}
+}
+
+// Certificate returns the certificate currently served.
+func (r *Reloader) Certificate() *tls.Certificate {
+	return r.cert.Load()
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Options controls New.
+type Options struct {
+	MinVersion uint16
+	// ClientCAs verifies client certificates; nil disables mutual TLS.
+	ClientCAs  *x509.CertPool
+	ClientAuth tls.ClientAuthType
+}
+
+// New builds a server tls.Config serving certificates from r.
+func New(r *Reloader, opts Options) *tls.Config {
+	cfg := &tls.Config{
+		GetCertificate: r.GetCertificate,
+		MinVersion:     opts.MinVersion,
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if opts.ClientCAs != nil {
+		cfg.ClientCAs = opts.ClientCAs
+		cfg.ClientAuth = opts.ClientAuth
+	}
+	return cfg
+}
+
+// ParseMinVersion parses "1.2" or "1.3"; empty means TLS 1.2.
+func ParseMinVersion(s string) (uint16, error) {
+	switch strings.TrimSpace(s) {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported minimum TLS version %q (use 1.2 or 1.3)", s)
+	}
+}
+
+// ParseClientAuth parses "none", "optional" (verify a certificate if one is
+// presented) or "require".
+func ParseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unsupported client auth mode %q (use none, optional or require)", s)
+	}
+}
+
+// LoadCertPool reads PEM CA certificates from path.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("client CA file contains no PEM certificates")
+	}
+	return pool, nil
+}
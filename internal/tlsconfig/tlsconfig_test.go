@@ -0,0 +1,182 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// newCertPEM returns a self-signed certificate and key for cn.
+func newCertPEM(t *testing.T, cn string) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeCert(t *testing.T, dir, cn string) FileSource {
+	t.Helper()
+	certPEM, keyPEM := newCertPEM(t, cn)
+	src := FileSource{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+	if err := os.WriteFile(src.CertFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src.KeyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestReloader_FileRotation(t *testing.T) {
+	dir := t.TempDir()
+	src := writeCert(t, dir, "old.example.com")
+	r, err := NewReloader(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+
+	if changed, err := r.Reload(context.Background()); err != nil || changed {
+		t.Errorf("Expected no change for unchanged files, got %v, %v", changed, err)
+	}
+
+	writeCert(t, dir, "new.example.com")
+	if changed, err := r.Reload(context.Background()); err != nil || !changed {
+		t.Fatalf("Expected rotation to be picked up, got %v, %v", changed, err)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if cn := cert.Leaf.Subject.CommonName; cn != "new.example.com" {
+		t.Errorf("Expected rotated certificate, got %s", cn)
+	}
+
+	// A broken write keeps the last good certificate
+	os.WriteFile(src.KeyFile, []byte("garbage"), 0o600)
+	if _, err := r.Reload(context.Background()); err == nil {
+		t.Error("Expected error for invalid key")
+	}
+	if cn := r.Certificate().Leaf.Subject.CommonName; cn != "new.example.com" {
+		t.Errorf("Expected previous certificate to remain, got %s", cn)
+	}
+}
+
+func TestNewReloader_InvalidSource(t *testing.T) {
+	if _, err := NewReloader(context.Background(), FileSource{CertFile: "missing.crt", KeyFile: "missing.key"}); err == nil {
+		t.Error("Expected error for missing files")
+	}
+}
+
+type fakeSecretsClient struct {
+	secret  string
+	version string
+	err     error
+}
+
+func (f *fakeSecretsClient) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(f.secret), VersionId: aws.String(f.version)}, nil
+}
+
+func TestReloader_SecretsManagerRotation(t *testing.T) {
+	secretFor := func(cn string) string {
+		certPEM, keyPEM := newCertPEM(t, cn)
+		return `{"certificate":` + quote(certPEM) + `,"privateKey":` + quote(keyPEM) + `}`
+	}
+	client := &fakeSecretsClient{secret: secretFor("v1.example.com"), version: "v1"}
+	r, err := NewReloader(context.Background(), SecretsManagerSource{Client: client, SecretID: "gateway/tls"})
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+
+	client.secret, client.version = secretFor("v2.example.com"), "v2"
+	if changed, err := r.Reload(context.Background()); err != nil || !changed {
+		t.Fatalf("Expected new version to be loaded, got %v, %v", changed, err)
+	}
+	if cn := r.Certificate().Leaf.Subject.CommonName; cn != "v2.example.com" {
+		t.Errorf("Expected v2 certificate, got %s", cn)
+	}
+
+	client.err = errors.New("throttled")
+	if _, err := r.Reload(context.Background()); err == nil {
+		t.Error("Expected fetch error")
+	}
+	if cn := r.Certificate().Leaf.Subject.CommonName; cn != "v2.example.com" {
+		t.Errorf("Expected v2 certificate to remain, got %s", cn)
+	}
+}
+
+func quote(b []byte) string {
+	out, _ := json.Marshal(string(b))
+	return string(out)
+}
+
+func TestParseMinVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint16
+		wantErr bool
+	}{
+		{in: "", want: tls.VersionTLS12},
+		{in: "1.2", want: tls.VersionTLS12},
+		{in: "1.3", want: tls.VersionTLS13},
+		{in: "1.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMinVersion(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMinVersion(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestParseClientAuth(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    tls.ClientAuthType
+		wantErr bool
+	}{
+		{in: "", want: tls.NoClientCert},
+		{in: "optional", want: tls.VerifyClientCertIfGiven},
+		{in: "REQUIRE", want: tls.RequireAndVerifyClientCert},
+		{in: "always", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseClientAuth(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseClientAuth(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}